@@ -8,11 +8,13 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"time"
 
 	"github.com/square/finch"
 	"github.com/square/finch/compute"
 	"github.com/square/finch/config"
+	"github.com/square/finch/results"
 )
 
 func init() {
@@ -56,6 +58,19 @@ func Up(env Env) error {
 		return nil
 	}
 
+	// finch compare RUN_A RUN_B: compare results bundles (--results) and exit
+	if isCompare(cmdline.Args) {
+		if len(cmdline.Args) != 4 {
+			return fmt.Errorf("Usage: finch compare RUN_A RUN_B")
+		}
+		for _, dir := range cmdline.Args[2:] {
+			if !isBundle(dir) {
+				return fmt.Errorf("%s is not a results bundle (no finch.json); usage: finch compare RUN_A RUN_B", dir)
+			}
+		}
+		return results.CompareDirs(os.Stdout, cmdline.Args[2], cmdline.Args[3])
+	}
+
 	log.Println(finch.SystemParams)
 
 	// Catch CTRL-C and cancel the main context, which should cause a clean shutdown
@@ -110,5 +125,35 @@ func Up(env Env) error {
 
 	// Boot and run each stage specified on the command line
 	server := compute.NewServer("local", cmdline.Options.Server, cmdline.Options.Test)
-	return server.Run(ctxFinch, stages)
+	if cmdline.Options.Results != "" && !cmdline.Options.Test {
+		server.Results, err = results.New(cmdline.Options.Results, env.Args)
+		if err != nil {
+			return err
+		}
+	}
+	err = server.Run(ctxFinch, stages)
+	if server.Results != nil {
+		if rerr := server.Results.Done(err); rerr != nil {
+			log.Printf("Error writing results: %s", rerr)
+		}
+	}
+	return err
+}
+
+// isCompare returns true if args is a "finch compare" command. If a stage file
+// named "compare" exists, it's run like any other stage file unless it's followed
+// by exactly two results bundles.
+func isCompare(args []string) bool {
+	if len(args) < 2 || args[1] != "compare" {
+		return false
+	}
+	if !config.FileExists("compare") {
+		return true
+	}
+	return len(args) == 4 && isBundle(args[2]) && isBundle(args[3])
+}
+
+// isBundle returns true if dir is a results bundle: a dir with finch.json.
+func isBundle(dir string) bool {
+	return config.FileExists(filepath.Join(dir, "finch.json"))
 }
@@ -2,6 +2,8 @@ package boot_test
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -45,6 +47,76 @@ func TestBootAndRuntime(t *testing.T) {
 	}
 }
 
+func TestCompare(t *testing.T) {
+	defer os.Chdir(cwd)
+
+	tmpdir := t.TempDir()
+	for _, run := range []string{"a", "b"} {
+		dir := filepath.Join(tmpdir, run)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "finch.json"), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chdir(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two results bundles: compare
+	err := boot.Up(boot.Env{Args: []string{"./finch", "compare", "a", "b"}})
+	if err != nil {
+		t.Errorf("compare two bundles: %s", err)
+	}
+
+	// Wrong number of args or not bundles: usage error, not stage loading
+	for _, args := range [][]string{
+		{"./finch", "compare"},
+		{"./finch", "compare", "a"},
+		{"./finch", "compare", "a", "c"},
+	} {
+		err = boot.Up(boot.Env{Args: args})
+		if err == nil || !strings.Contains(err.Error(), "finch compare RUN_A RUN_B") {
+			t.Errorf("%v: got error '%v', expected usage error", args, err)
+		}
+	}
+
+}
+
+func TestCompare_StageFile(t *testing.T) {
+	if test.Build {
+		t.Skip("GitHub Actions build")
+	}
+
+	defer os.Chdir(cwd)
+
+	dsn, db, err := test.Connection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// A stage file named compare is a stage file, not the compare command,
+	// unless it's followed by two results bundles
+	tmpdir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpdir, "select-1.sql"), []byte("SELECT 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stage := "stage:\n  runtime: 1s\n  trx:\n    - file: select-1.sql\n"
+	if err := os.WriteFile(filepath.Join(tmpdir, "compare"), []byte(stage), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	err = boot.Up(boot.Env{Args: []string{"./finch", "--dsn", dsn, "--test", "compare"}})
+	if err != nil {
+		t.Errorf("stage file compare: %s", err)
+	}
+}
+
 func TestColumns(t *testing.T) {
 	if test.Build {
 		t.Skip("GitHub Actions build")
@@ -19,6 +19,7 @@ type Options struct {
 	DSN        string `arg:"env:FINCH_DSN"`
 	Help       bool
 	Params     []string `arg:"-p,--param,separate"`
+	Results    string   `arg:"env:FINCH_RESULTS"`
 	Server     string   `arg:"env:FINCH_SERVER"`
 	Test       bool     `arg:"env:FINCH_TEST"`
 	Version    bool
@@ -50,7 +51,8 @@ func ParseCommandLine(args []string) (CommandLine, error) {
 
 func printHelp() {
 	fmt.Printf("Usage:\n"+
-		"  finch [options] STAGE_1_FILE [STAGE_N_FILE...]\n\n"+
+		"  finch [options] STAGE_1_FILE [STAGE_N_FILE...]\n"+
+		"  finch compare RUN_A RUN_B\n\n"+
 		"Options:\n"+
 		"  --client ADDR[:PORT]  Run as client of server at ADDR\n"+
 		"  --cpu-profile FILE    Save CPU profile of stage execution to FILE\n"+
@@ -59,6 +61,7 @@ func printHelp() {
 		"  --dsn DSN             MySQL DSN (overrides stage files)\n"+
 		"  --help                Print help and exit\n"+
 		"  --param (-p) KEY=VAL  Set param key=value (override stage files)\n"+
+		"  --results DIR         Save results bundle to DIR\n"+
 		"  --server ADDR[:PORT]  Run as server on ADDR\n"+
 		"  --test                Validate stages, test connections, and exit\n"+
 		"  --version             Print version and exit\n"+
@@ -5,6 +5,7 @@ package client
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"log"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/square/finch"
 	"github.com/square/finch/data"
+	"github.com/square/finch/idle"
 	"github.com/square/finch/stats"
 	"github.com/square/finch/trx"
 )
@@ -23,8 +25,14 @@ import (
 var (
 	ConnectTimeout   = 500 * time.Millisecond
 	ConnectRetryWait = 200 * time.Millisecond
+	ErrorRetries     = uint(3) // default max finch.Eretry per trx (Client.Retries)
+	MaxReconnects    = 100     // max Client.Reconnects; ReconnectTotal counts all
 )
 
+// errRetry is returned by Connect when the trx should be executed again from its
+// first statement.
+var errRetry = errors.New("retry")
+
 // Client executes SQL statements. Each client is created in workload.Allocator.Clients
 // and run in Stage.Run. Client.Init must be called once before calling Client.Run once.
 type Client struct {
@@ -38,6 +46,12 @@ type Client struct {
 
 	// Optional, usually from stage config
 	DefaultDb        string
+	Session          string          // SET SESSION on connect (config.Session.SQL)
+	ErrorHandling    map[uint16]byte // default: finch.MySQLErrorHandling
+	Retries          uint            // max finch.Eretry per trx; default: ErrorRetries
+	IdleIter         idle.Time       // think time between iterations
+	IdleTrx          idle.Time       // think time between trx
+	Timeout          time.Duration   // default statement timeout
 	IterExecGroup    uint32
 	IterExecGroupPtr *uint32
 	IterClients      uint32
@@ -45,14 +59,34 @@ type Client struct {
 	Iter             uint
 	QPS              <-chan bool
 	TPS              <-chan bool
+	Times            []StatementTime  // per statement, if not nil (config.stats.digests)
+	LockErrors       chan<- LockError // deadlock and lock wait timeout errors, if not nil
+	Bytes            bool             // count bytes received (config.Stats.Throughput)
+
+	// Connection lifecycle (config.Connection): new connection every trx, every
+	// ConnIter iterations, or every ConnTime, in addition to reconnect on error
+	ConnTrx  bool
+	ConnIter uint
+	ConnTime time.Duration
+
+	// Reconnects is the timeline of reconnects after errors, in order, up to
+	// MaxReconnects. ReconnectTotal is all reconnects. Set by Run; read after
+	// the client is done.
+	Reconnects     []Reconnect
+	ReconnectTotal ReconnectTotal
 
 	// Retrun value to DoneChane
 	Error Error
 
 	// --
-	ps     []*sql.Stmt
-	values [][]interface{}
-	conn   *sql.Conn
+	ps      []*sql.Stmt
+	values  [][]interface{}
+	raw     [][]interface{} // *sql.RawBytes to read rows not saved
+	timeout []time.Duration
+	conn    *sql.Conn
+	retries uint      // finch.Eretry for current trx
+	connect int64     // latency of last successful DB.Conn (microseconds), recorded by Run
+	connAt  time.Time // when last connected, for ConnTime
 }
 
 type Error struct {
@@ -60,6 +94,39 @@ type Error struct {
 	StatementNo int
 }
 
+// StatementTime is the client-side response time of a statement: number of
+// executions and total time (microseconds). It's optional because stats are
+// per trx, not per statement; it's used for the digest report.
+type StatementTime struct {
+	N   uint64
+	Sum int64
+}
+
+// LockError is a deadlock (1213) or lock wait timeout (1205) error sent to
+// Client.LockErrors for lock diagnostics (config.stats.lock-diagnostics).
+type LockError struct {
+	Code      uint16
+	Statement *trx.Statement
+}
+
+// Reconnect is a disconnect and reconnect caused by an error. Downtime is the time
+// from Connect called on error until connected again, including retry waits and
+// failed connection attempts: how long the client was unable to reach MySQL.
+type Reconnect struct {
+	Time     time.Time // when Connect was called on error
+	Code     uint16    // MySQL error code, or 0 if not a MySQL error
+	Error    string
+	Downtime time.Duration
+}
+
+// ReconnectTotal is the number of reconnects and their total and max downtime,
+// including reconnects not in Client.Reconnects because of MaxReconnects.
+type ReconnectTotal struct {
+	N        uint
+	Downtime time.Duration
+	Max      time.Duration
+}
+
 type StatementData struct {
 	Inputs      []data.ValueFunc `deep:"-"` // input to query
 	Outputs     []interface{}    `deep:"-"` // output from query; values are data.Generator
@@ -70,10 +137,22 @@ type StatementData struct {
 func (c *Client) Init() error {
 	c.ps = make([]*sql.Stmt, len(c.Statements))
 	c.values = make([][]interface{}, len(c.Statements))
+	c.raw = make([][]interface{}, len(c.Statements))
+	c.timeout = make([]time.Duration, len(c.Statements))
 	for i, s := range c.Statements {
 		if len(s.Inputs) > 0 {
 			c.values[i] = make([]interface{}, len(s.Inputs))
 		}
+		c.timeout[i] = s.Timeout // trx modifier overrides client group timeout
+		if c.timeout[i] == 0 {
+			c.timeout[i] = c.Timeout
+		}
+	}
+	if c.ErrorHandling == nil {
+		c.ErrorHandling = finch.MySQLErrorHandling
+	}
+	if c.Retries == 0 {
+		c.Retries = ErrorRetries
 	}
 	c.Error = Error{}
 	return nil
@@ -83,6 +162,7 @@ func (c *Client) Connect(ctx context.Context, cerr error, stmtNo int, trxActive
 	if ctx.Err() != nil { // finch terminated (CTRL-C)?
 		return ctx.Err()
 	}
+	c.connect = -1 // not connected unless set below
 
 	// @todo: handled errors aren't printed, can't tell what went wrong
 	//        when errors col != 0
@@ -90,20 +170,30 @@ func (c *Client) Connect(ctx context.Context, cerr error, stmtNo int, trxActive
 	silent := false
 	// Connect called due to error on query execution?
 	if cerr != nil {
-		errFlags, handled := finch.MySQLErrorHandling[myerr.MySQLErrorCode(cerr)]
+		errCode := ErrorCode(cerr)
+		errFlags, handled := c.ErrorHandling[errCode]
 		if c.Statements[stmtNo].DDL && !handled {
 			return fmt.Errorf("DDL: %s", cerr)
 		}
-		if handled {
-			if errFlags&finch.Eabort != 0 {
-				return cerr // stop client
-			}
-			if errFlags&finch.Erollback != 0 && trxActive {
+		if handled && errFlags == finch.Eabort {
+			return cerr // stop client
+		}
+		// Statement timeout: the driver closed the conn when the ctx was cancelled,
+		// and MySQL rolls back the trx on disconnect, so always reconnect
+		if handled && errCode != finch.ErrTimeout {
+			// Retry executes the whole trx again, so roll back what MySQL didn't
+			// already roll back (like a deadlock) to not commit a partial trx
+			retry := errFlags&finch.Eretry != 0 && c.retries < c.Retries
+			if (errFlags&finch.Erollback != 0 || retry) && trxActive {
 				finch.Debug("%s: rollback", c.RunLevel.ClientId())
 				if _, err := c.conn.ExecContext(ctx, "ROLLBACK"); err != nil {
 					return fmt.Errorf("ROLLBACK failed: %s (on err: %s) (query: %s)", err, cerr, c.Statements[stmtNo].Query)
 				}
 			}
+			if retry {
+				c.retries++
+				return errRetry // keep conn, execute trx again
+			}
 			if errFlags&finch.Econtinue != 0 {
 				return nil // keep conn, next iter, keep executing
 			}
@@ -114,18 +204,22 @@ func (c *Client) Connect(ctx context.Context, cerr error, stmtNo int, trxActive
 		}
 	}
 
+	tDown := time.Now()
 	if c.conn != nil {
-		c.conn.Close()
-		c.conn = nil
+		c.closeStmts() // prepared on the old conn; prepared again below
+		c.discardConn()
 		time.Sleep(ConnectRetryWait)
 	}
 
 	t0 := time.Now()
 	for ctx.Err() == nil {
 		ctxConn, cancel := context.WithTimeout(ctx, ConnectTimeout)
+		t := time.Now()
 		c.conn, _ = c.DB.Conn(ctxConn)
 		cancel()
 		if c.conn != nil {
+			c.connect = time.Now().Sub(t).Microseconds()
+			c.connAt = time.Now()
 			break // success
 		}
 		time.Sleep(ConnectRetryWait)
@@ -135,8 +229,24 @@ func (c *Client) Connect(ctx context.Context, cerr error, stmtNo int, trxActive
 		return ctx.Err()
 	}
 
-	if cerr != nil && !silent {
-		log.Printf("Client %s reconnected in %.3fs", c.RunLevel.ClientId(), time.Now().Sub(t0).Seconds())
+	if cerr != nil {
+		downtime := time.Now().Sub(tDown)
+		c.ReconnectTotal.N++
+		c.ReconnectTotal.Downtime += downtime
+		if downtime > c.ReconnectTotal.Max {
+			c.ReconnectTotal.Max = downtime
+		}
+		if len(c.Reconnects) < MaxReconnects {
+			c.Reconnects = append(c.Reconnects, Reconnect{
+				Time:     tDown,
+				Code:     ErrorCode(cerr),
+				Error:    cerr.Error(),
+				Downtime: downtime,
+			})
+		}
+		if !silent {
+			log.Printf("Client %s reconnected in %.3fs", c.RunLevel.ClientId(), time.Now().Sub(t0).Seconds())
+		}
 	}
 
 	if c.DefaultDb != "" {
@@ -146,6 +256,13 @@ func (c *Client) Connect(ctx context.Context, cerr error, stmtNo int, trxActive
 		}
 	}
 
+	if c.Session != "" {
+		_, err := c.conn.ExecContext(ctx, c.Session)
+		if err != nil {
+			return fmt.Errorf("session: %s", err)
+		}
+	}
+
 	var err error
 	for i, s := range c.Statements {
 		if !s.Prepare {
@@ -179,12 +296,7 @@ func (c *Client) Run(ctxExec context.Context) {
 			n := runtime.Stack(b, false)
 			err = fmt.Errorf("PANIC: %v\n%s", r, string(b[0:n]))
 		}
-		for i := range c.ps {
-			if c.ps[i] == nil {
-				continue
-			}
-			c.ps[i].Close()
-		}
+		c.closeStmts()
 		if c.conn != nil {
 			c.conn.Close()
 		}
@@ -198,10 +310,17 @@ func (c *Client) Run(ctxExec context.Context) {
 	if err = c.Connect(ctxExec, nil, -1, false); err != nil {
 		return
 	}
+	if len(c.Stats) > 0 && c.Stats[0] != nil {
+		c.Stats[0].Record(stats.CONNECT, c.connect) // first trx, connected before it
+	}
 
 	var rc data.RunCount
 	rc[data.CONN] = 1 // first MySQL connection ^
 
+	// Connection lifecycle: trx and iteration numbers of the current connection
+	connTrx := uint(1)
+	connIter := uint(1)
+
 	// Not counts but passed with RunCount in case a data.Generator wants to know
 	rc[data.CLIENT] = c.RunLevel.Client
 	rc[data.CLIENT_GROUP] = c.RunLevel.ClientGroup
@@ -210,14 +329,20 @@ func (c *Client) Run(ctxExec context.Context) {
 
 	var rows *sql.Rows
 	var res sql.Result
+	var q string // query sent, for stats bytes sent
 	var t time.Time
+	var ctx context.Context       // ctxExec or statement timeout
+	var cancel context.CancelFunc // statement timeout
 
 	// trxNo indexes into c.Stats and resets to 0 on each iteration. Remember:
 	// these are finch trx (files), not MySQL trx, so trx boundaries mark the
 	// beginning and end of a finch trx (file). User is expected to make finch
 	// trx boundaries meaningful.
 	trxNo := -1
+	trxFirst := -1 // first statement of current trx, for retry
 	trxActive := false
+	var trxStart time.Time // stats.TRX
+	trxTimed := false      // trxStart set for current trx
 
 	//
 	// CRITICAL LOOP: no debug or superfluous function calls
@@ -233,28 +358,76 @@ ITER:
 		if c.Iter > 0 && rc[data.ITER] == c.Iter {
 			return
 		}
+		if c.IdleIter != nil && rc[data.ITER] > 0 {
+			time.Sleep(c.IdleIter.Duration())
+		}
 		rc[data.ITER] += 1
 		trxNo = -1
+		trxFirst = -1
 		trxActive = false
-
-		for i := range c.Statements {
-			// Idle time
-			if c.Statements[i].Idle != 0 {
-				time.Sleep(c.Statements[i].Idle)
-				continue
+		trxTimed = false
+
+		// New connection before iteration, if due (config.Connection). This is
+		// checked here, not at trx BEGIN, so it's honored without trx boundaries.
+		if (c.ConnIter > 0 && rc[data.ITER]-connIter >= c.ConnIter) ||
+			(c.ConnTime > 0 && time.Since(c.connAt) >= c.ConnTime) {
+			if err = c.newConn(ctxExec); err != nil {
+				return
+			}
+			connTrx = rc[data.TRX] + 1 // next trx is the first on the new conn
+			connIter = rc[data.ITER]
+			rc[data.CONN] += 1
+			if len(c.Stats) > 0 && c.Stats[0] != nil {
+				c.Stats[0].Record(stats.CONNECT, c.connect) // first trx, connected before it
 			}
+		}
 
+		for i := 0; i < len(c.Statements); i++ {
 			// Is this query the start of a new (finch) trx file? This is not
 			// a MySQL trx (either BEGIN or implicit). It marks finch trx scope
 			// "trx" is a trx file in the config assigned to this client.
 			if c.Data[i].TrxBoundary&trx.BEGIN != 0 {
+				if c.IdleTrx != nil && trxNo >= 0 {
+					time.Sleep(c.IdleTrx.Duration())
+				}
 				rc[data.TRX] += 1
 				trxNo += 1
 				trxActive = true
+				trxTimed = false
+				if i != trxFirst { // not retry
+					trxFirst = i
+					c.retries = 0
+				}
+
+				// New connection before trx, if due (config.Connection)
+				if (c.ConnTrx && rc[data.TRX] != connTrx) ||
+					(c.ConnTime > 0 && time.Since(c.connAt) >= c.ConnTime) {
+					if err = c.newConn(ctxExec); err != nil {
+						c.Error.StatementNo = i
+						return
+					}
+					connTrx = rc[data.TRX]
+					connIter = rc[data.ITER]
+					rc[data.CONN] += 1
+					if c.Stats[trxNo] != nil {
+						c.Stats[trxNo].Record(stats.CONNECT, c.connect)
+					}
+				}
 			} else if c.Data[i].TrxBoundary&trx.END != 0 {
 				trxActive = false
 			}
 
+			// Idle time: not a query, so no rate limits or stats. Trx boundaries
+			// are handled above, but stats.TRX is only real statements: it starts
+			// at the first query and, if idle ends the trx, stops before idle.
+			if c.Statements[i].Idle != nil {
+				if c.Data[i].TrxBoundary&trx.END != 0 && trxTimed && c.Stats[trxNo] != nil {
+					c.Stats[trxNo].Record(stats.TRX, time.Now().Sub(trxStart).Microseconds())
+				}
+				time.Sleep(c.Statements[i].Idle.Duration())
+				continue
+			}
+
 			// If BEGIN, check TPS rate limiter
 			if c.TPS != nil && c.Statements[i].Begin {
 				<-c.TPS
@@ -265,6 +438,13 @@ ITER:
 				<-c.QPS
 			}
 
+			// Finch trx response time starts after rate limits (not including them)
+			// at the first query, which is BEGIN unless idle precedes it
+			if !trxTimed {
+				trxStart = time.Now()
+				trxTimed = true
+			}
+
 			// Generate new data values for this query. A single data generator
 			// can return multiple values, so d makes copy() append, else copy()
 			// would start at [0:] each time
@@ -274,22 +454,114 @@ ITER:
 				d += copy(c.values[i][d:], f(rc))
 			}
 
-			if c.Statements[i].ResultSet {
+			// Statement timeout, if any
+			ctx = ctxExec
+			if c.timeout[i] > 0 {
+				ctx, cancel = context.WithTimeout(ctxExec, c.timeout[i])
+			}
+
+			if c.Statements[i].Batch > 0 {
+				//
+				// Batch: this and the next Batch-1 statements in one round trip
+				//
+				n := c.Statements[i].Batch
+				q = fmt.Sprintf(c.Statements[i].Query, c.values[i]...)
+				for j := i + 1; j < i+n; j++ {
+					if c.Data[j].TrxBoundary&trx.END != 0 {
+						trxActive = false
+					}
+					if c.TPS != nil && c.Statements[j].Begin {
+						<-c.TPS
+					}
+					if c.QPS != nil {
+						<-c.QPS
+					}
+					rc[data.STATEMENT] += 1
+					d := 0
+					for _, f := range c.Data[j].Inputs {
+						d += copy(c.values[j][d:], f(rc))
+					}
+					q += ";\n" + fmt.Sprintf(c.Statements[j].Query, c.values[j]...)
+				}
+				var done int
+				t = time.Now()
+				done, err = c.execBatch(ctx, q, i, n)
+				if c.Stats[trxNo] != nil {
+					// Every statement has the response time of the batch, but
+					// only statements that MySQL executed are recorded: those
+					// done and the one that failed, if any
+					d := time.Now().Sub(t).Microseconds()
+					c.Stats[trxNo].Record(stats.BATCH, d)
+					c.Stats[trxNo].Add(0, 0, uint64(len(q)), 0)
+					last := i + done
+					if err != nil {
+						last++
+					}
+					for j := i; j < last; j++ {
+						c.record(c.Stats[trxNo], j, d)
+					}
+				}
+				if err != nil {
+					i += done // statement that failed
+					goto ERROR
+				}
+				i += n - 1 // skip batched statements; loop i++ to next statement
+			} else if c.Statements[i].Call {
+				//
+				// CALL
+				//
+				// A procedure can return several result sets; all must be
+				// read before the next query on the connection, and response
+				// time includes reading them because the procedure is still
+				// running until the last one.
+				t = time.Now()
+				if c.ps[i] != nil {
+					q = c.Statements[i].Query
+					rows, err = c.ps[i].QueryContext(ctx, c.values[i]...)
+				} else {
+					q = fmt.Sprintf(c.Statements[i].Query, c.values[i]...)
+					rows, err = c.conn.QueryContext(ctx, q)
+				}
+				var nRows uint64
+				if err == nil {
+					nRows, err = c.drain(rows, i)
+				}
+				if c.Stats[trxNo] != nil {
+					d := time.Now().Sub(t).Microseconds()
+					c.Stats[trxNo].Record(stats.CALL, d)
+					c.Stats[trxNo].Record(stats.TOTAL, d)
+					c.Stats[trxNo].Add(nRows, 0, uint64(len(q)), 0)
+				}
+				if c.Times != nil {
+					c.Times[i].N++
+					c.Times[i].Sum += time.Now().Sub(t).Microseconds()
+				}
+				if err != nil {
+					goto ERROR
+				}
+			} else if c.Statements[i].ResultSet {
 				//
 				// SELECT
 				//
 				t = time.Now()
 				if c.ps[i] != nil {
-					rows, err = c.ps[i].QueryContext(ctxExec, c.values[i]...)
+					q = c.Statements[i].Query
+					rows, err = c.ps[i].QueryContext(ctx, c.values[i]...)
 				} else {
-					rows, err = c.conn.QueryContext(ctxExec, fmt.Sprintf(c.Statements[i].Query, c.values[i]...))
+					q = fmt.Sprintf(c.Statements[i].Query, c.values[i]...)
+					rows, err = c.conn.QueryContext(ctx, q)
 				}
 				if c.Stats[trxNo] != nil {
-					c.Stats[trxNo].Record(stats.READ, time.Now().Sub(t).Microseconds())
+					d := time.Now().Sub(t).Microseconds()
+					c.Stats[trxNo].Record(stats.READ, d)
+					if c.Statements[i].LockingRead {
+						c.Stats[trxNo].Record(stats.LOCKING_READ, d)
+					}
 				}
 				if err != nil {
 					goto ERROR
 				}
+				var nRows, nBytes uint64
 				if c.Data[i].Outputs != nil {
 					// @todo what if no row match? This loop won't happen,
 					// and the column generator won't be called, which will
@@ -297,29 +569,57 @@ ITER:
 					// query.
 					for rows.Next() {
 						if err = rows.Scan(c.Data[i].Outputs...); err != nil {
-							rows.Close()
-							goto ERROR
+							break
 						}
+						nRows++
 					}
+					// Bytes recv unknown: values are scanned into data generators
+				} else {
+					nRows, nBytes = c.read(rows, i)
+				}
+				if err == nil {
+					err = rows.Err() // error reading rows, like timeout or query killed
 				}
 				rows.Close()
+				if c.Stats[trxNo] != nil {
+					c.Stats[trxNo].Add(nRows, 0, uint64(len(q)), nBytes)
+				}
+				if err != nil {
+					goto ERROR
+				}
+				if c.Times != nil { // includes reading rows, like server-side time
+					c.Times[i].N++
+					c.Times[i].Sum += time.Now().Sub(t).Microseconds()
+				}
 			} else {
 				//
 				// Write or query without result set (e.g. BEGIN, SET, etc.)
 				//
 				if c.Statements[i].Limit != nil { // limit rows -------------
 					if !c.Statements[i].Limit.More(c.conn) {
+						if cancel != nil {
+							cancel()
+						}
 						return // chan closed = no more writes
 					}
 				}
+				if c.Statements[i].DDL && c.Stats[trxNo] != nil {
+					c.Stats[trxNo].DDL(true) // mark DML stats during DDL
+				}
 				t = time.Now()
 				if c.ps[i] != nil { // exec ---------------------------------
-					res, err = c.ps[i].ExecContext(ctxExec, c.values[i]...)
+					q = c.Statements[i].Query
+					res, err = c.ps[i].ExecContext(ctx, c.values[i]...)
 				} else {
-					res, err = c.conn.ExecContext(ctxExec, fmt.Sprintf(c.Statements[i].Query, c.values[i]...))
+					q = fmt.Sprintf(c.Statements[i].Query, c.values[i]...)
+					res, err = c.conn.ExecContext(ctx, q)
 				}
 				if c.Stats[trxNo] != nil { // record stats ------------------
 					switch {
+					case c.Statements[i].DDL:
+						c.Stats[trxNo].Record(stats.DDL, time.Now().Sub(t).Microseconds())
+						c.Stats[trxNo].Record(stats.TOTAL, time.Now().Sub(t).Microseconds())
+						c.Stats[trxNo].DDL(false)
 					case c.Statements[i].Write:
 						c.Stats[trxNo].Record(stats.WRITE, time.Now().Sub(t).Microseconds())
 					case c.Statements[i].Commit:
@@ -330,30 +630,245 @@ ITER:
 						c.Stats[trxNo].Record(stats.TOTAL, time.Now().Sub(t).Microseconds())
 					}
 				}
+				if c.Times != nil {
+					c.Times[i].N++
+					c.Times[i].Sum += time.Now().Sub(t).Microseconds()
+				}
 				if err != nil { // handle err, if any -----------------------
 					goto ERROR
 				}
-				if c.Statements[i].Limit != nil { // limit rows -------------
+				if c.Statements[i].Limit != nil || c.Stats[trxNo] != nil { // rows affected
 					n, _ := res.RowsAffected()
-					c.Statements[i].Limit.Affected(n)
+					if c.Statements[i].Limit != nil {
+						c.Statements[i].Limit.Affected(n)
+					}
+					if c.Stats[trxNo] != nil {
+						c.Stats[trxNo].Add(0, uint64(n), uint64(len(q)), 0)
+					}
 				}
 				if c.Data[i].InsertId != nil { // insert ID -----------------
 					id, _ := res.LastInsertId()
 					c.Data[i].InsertId.Scan(id)
 				}
 			} // execute
+			if cancel != nil {
+				cancel()
+				cancel = nil
+			}
+			if c.Data[i].TrxBoundary&trx.END != 0 && c.Stats[trxNo] != nil {
+				// End of finch trx, including idle between queries, but not if any query failed
+				c.Stats[trxNo].Record(stats.TRX, time.Now().Sub(trxStart).Microseconds())
+			}
 			continue // next query
 
 		ERROR:
+			if cancel != nil {
+				cancel()
+				cancel = nil
+			}
 			if c.Stats[trxNo] != nil && ctxExec.Err() == nil {
-				c.Stats[trxNo].Error(myerr.MySQLErrorCode(err))
+				c.Stats[trxNo].Error(ErrorCode(err))
+			}
+			if c.LockErrors != nil {
+				if code := ErrorCode(err); code == 1213 || code == 1205 {
+					select {
+					case c.LockErrors <- LockError{Code: code, Statement: c.Statements[i]}:
+					default: // don't block client; diagnostics are rate limited anyway
+					}
+				}
 			}
 			if err = c.Connect(ctxExec, err, i, trxActive); err != nil {
+				if err == errRetry {
+					if trxFirst < 0 {
+						continue ITER // no trx boundary to retry from; keep conn, next iter
+					}
+					// Execute the trx again from its first statement, not only
+					// the failed statement, because the trx was rolled back.
+					// Undo trx boundary because it's counted again on retry.
+					rc[data.TRX] -= 1
+					trxNo -= 1
+					i = trxFirst - 1
+					continue // first statement in trx
+				}
 				c.Error.StatementNo = i
 				return // unrecoverable error or runtime elapsed (context timeout/cancel)
 			}
 			rc[data.CONN] += 1 // reconnected or recovered after query error
+			if c.connect >= 0 {
+				// Reconnected: next trx and iteration are the first on the new conn
+				connTrx = rc[data.TRX] + 1
+				connIter = rc[data.ITER] + 1
+			}
+			if c.connect >= 0 && trxNo >= 0 && c.Stats[trxNo] != nil {
+				c.Stats[trxNo].Record(stats.CONNECT, c.connect)
+			}
 			continue ITER
 		} // statements
 	} // iterations
 }
+
+// newConn makes a new MySQL connection for the connection lifecycle (ConnTrx,
+// ConnIter, ConnTime). Connect applies the default db and session variables,
+// and prepares statements again.
+func (c *Client) newConn(ctx context.Context) error {
+	c.closeStmts()
+	if c.conn != nil {
+		c.discardConn()
+	}
+	return c.Connect(ctx, nil, -1, false)
+}
+
+// discardConn closes the connection and removes it from the connection pool,
+// so DB.Conn makes a new MySQL connection. Conn.Close alone returns it to the
+// pool, so DB.Conn could return the same MySQL connection.
+func (c *Client) discardConn() {
+	// Returning driver.ErrBadConn makes database/sql close the connection
+	c.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	c.conn.Close()
+	c.conn = nil
+}
+
+// closeStmts closes prepared statements, which are bound to the connection.
+func (c *Client) closeStmts() {
+	for i := range c.ps {
+		if c.ps[i] == nil {
+			continue
+		}
+		c.ps[i].Close() // same ps for prepare multi, but Close is idempotent
+		c.ps[i] = nil
+	}
+}
+
+// execBatch executes batched statements i through i+n-1 as one multi-statement
+// query. It returns the number of statements known to be done. On error, the
+// statement that failed is i+done: MySQL stops executing a batch on the first
+// error, but statements without a result set (like UPDATE) return no result set
+// to mark their completion, so an error is attributed to the first statement
+// after the last result set read.
+func (c *Client) execBatch(ctx context.Context, query string, i, n int) (int, error) {
+	rows, err := c.conn.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	done := 0
+	first := true // QueryContext returns the first result set, if any
+	for j := i; j < i+n; j++ {
+		if !c.Statements[j].ResultSet {
+			continue
+		}
+		if !first && !rows.NextResultSet() {
+			err = rows.Err()
+			rows.Close()
+			if err == nil {
+				err = fmt.Errorf("no result set for batched statement %d: %s", j, c.Statements[j].Query)
+			}
+			return done, err
+		}
+		first = false
+		for rows.Next() {
+			if c.Data[j].Outputs != nil {
+				if err = rows.Scan(c.Data[j].Outputs...); err != nil {
+					rows.Close()
+					return j - i, err
+				}
+			}
+		}
+		if err = rows.Err(); err != nil {
+			rows.Close()
+			return j - i, err
+		}
+		done = j - i + 1
+	}
+	// Close reads the remaining results, which returns an error from any
+	// statement after the last result set
+	if err = rows.Close(); err != nil {
+		return done, err
+	}
+	return n, nil
+}
+
+// record records the response time of statement i by its type. It's used for
+// batched statements; the critical loop in Run records other statements inline.
+func (c *Client) record(s *stats.Trx, i int, d int64) {
+	switch {
+	case c.Statements[i].ResultSet:
+		s.Record(stats.READ, d)
+		if c.Statements[i].LockingRead {
+			s.Record(stats.LOCKING_READ, d)
+		}
+	case c.Statements[i].Write:
+		s.Record(stats.WRITE, d)
+	case c.Statements[i].Commit:
+		s.Record(stats.COMMIT, d)
+	case c.Statements[i].DDL:
+		s.Record(stats.DDL, d)
+		s.Record(stats.TOTAL, d)
+	default:
+		s.Record(stats.TOTAL, d)
+	}
+}
+
+// drain reads and closes all result sets from CALL statement i. If the statement
+// has outputs (save-columns), they're scanned from result set CallResult.
+func (c *Client) drain(rows *sql.Rows, i int) (uint64, error) {
+	defer rows.Close()
+	n := 1
+	var nRows uint64
+	for {
+		save := c.Data[i].Outputs != nil && n == c.Statements[i].CallResult
+		for rows.Next() {
+			nRows++
+			if save {
+				if err := rows.Scan(c.Data[i].Outputs...); err != nil {
+					return nRows, err
+				}
+			}
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+		n++
+	}
+	return nRows, rows.Err()
+}
+
+// read reads all rows from statement i without saving them, and returns the
+// number of rows and, if Bytes is true, approximate bytes: the length of all
+// column values. The caller must check rows.Err.
+func (c *Client) read(rows *sql.Rows, i int) (nRows, nBytes uint64) {
+	if !c.Bytes {
+		for rows.Next() {
+			nRows++
+		}
+		return
+	}
+	if c.raw[i] == nil {
+		cols, err := rows.Columns()
+		if err != nil {
+			return
+		}
+		c.raw[i] = make([]interface{}, len(cols))
+		for j := range cols {
+			c.raw[i][j] = new(sql.RawBytes)
+		}
+	}
+	for rows.Next() {
+		nRows++
+		if err := rows.Scan(c.raw[i]...); err != nil {
+			continue // column count changed, like SELECT * after ALTER; count rows only
+		}
+		for _, v := range c.raw[i] {
+			nBytes += uint64(len(*v.(*sql.RawBytes)))
+		}
+	}
+	return
+}
+
+// ErrorCode returns the MySQL error code of err, or finch.ErrTimeout if err
+// is a statement timeout. It returns 0 for other non-MySQL errors.
+func ErrorCode(err error) uint16 {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return finch.ErrTimeout
+	}
+	return myerr.MySQLErrorCode(err)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/square/finch"
 	"github.com/square/finch/client"
 	"github.com/square/finch/data"
+	"github.com/square/finch/idle"
 	"github.com/square/finch/stats"
 	"github.com/square/finch/test"
 	"github.com/square/finch/trx"
@@ -165,3 +166,334 @@ func TestClient_Write(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestClient_ConnTrx(t *testing.T) {
+	if test.Build {
+		t.Skip("GitHub Actions build")
+	}
+
+	_, db, err := test.Connection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	doneChan := make(chan *client.Client, 1)
+	trxStats := stats.NewTrx("t1")
+
+	// New connection every trx: the prepared statement must be prepared again
+	// on each new connection, and each connect is recorded
+	c := &client.Client{
+		DB:       db,
+		RunLevel: rl,
+		DoneChan: doneChan,
+		Statements: []*trx.Statement{
+			{
+				Query:     "SELECT 1",
+				ResultSet: true,
+				Prepare:   true,
+			},
+		},
+		Data: []client.StatementData{
+			{
+				TrxBoundary: trx.BEGIN | trx.END,
+			},
+		},
+		Stats:   []*stats.Trx{trxStats},
+		Iter:    3,
+		ConnTrx: true,
+	}
+
+	err = c.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Run(context.Background())
+
+	timeout := time.After(2 * time.Second)
+	var ret *client.Client
+	select {
+	case ret = <-doneChan:
+	case <-timeout:
+		t.Fatal("Client timeout after 2s")
+	}
+	if ret.Error.Err != nil {
+		t.Errorf("Client error: %v", ret.Error.Err)
+	}
+
+	s := trxStats.Swap()
+	if s.N[stats.CONNECT] != 3 {
+		t.Errorf("got %d connects, expected 3 (1 per trx)", s.N[stats.CONNECT])
+	}
+	if s.N[stats.READ] != 3 {
+		t.Errorf("got %d reads, expected 3", s.N[stats.READ])
+	}
+}
+
+func TestClient_ConnIter(t *testing.T) {
+	if test.Build {
+		t.Skip("GitHub Actions build")
+	}
+
+	_, db, err := test.Connection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	doneChan := make(chan *client.Client, 1)
+	trxStats := stats.NewTrx("t1")
+
+	// New connection every 2 iterations: connect before iter 1 and 3
+	c := &client.Client{
+		DB:       db,
+		RunLevel: rl,
+		DoneChan: doneChan,
+		Statements: []*trx.Statement{
+			{
+				Query:     "SELECT 1",
+				ResultSet: true,
+				Prepare:   true,
+			},
+		},
+		Data: []client.StatementData{
+			{
+				TrxBoundary: trx.BEGIN | trx.END,
+			},
+		},
+		Stats:    []*stats.Trx{trxStats},
+		Iter:     4,
+		ConnIter: 2,
+	}
+
+	err = c.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Run(context.Background())
+
+	timeout := time.After(2 * time.Second)
+	var ret *client.Client
+	select {
+	case ret = <-doneChan:
+	case <-timeout:
+		t.Fatal("Client timeout after 2s")
+	}
+	if ret.Error.Err != nil {
+		t.Errorf("Client error: %v", ret.Error.Err)
+	}
+
+	s := trxStats.Swap()
+	if s.N[stats.CONNECT] != 2 {
+		t.Errorf("got %d connects, expected 2 (1 per 2 iter)", s.N[stats.CONNECT])
+	}
+	if s.N[stats.READ] != 4 {
+		t.Errorf("got %d reads, expected 4", s.N[stats.READ])
+	}
+	if ret.ReconnectTotal.N != 0 {
+		t.Errorf("got %d reconnects, expected 0 (new conns are not reconnects on error)", ret.ReconnectTotal.N)
+	}
+}
+
+func TestClient_IdleTrxBoundary(t *testing.T) {
+	if test.Build {
+		t.Skip("GitHub Actions build")
+	}
+
+	_, db, err := test.Connection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	doneChan := make(chan *client.Client, 1)
+	trxStats := stats.NewTrx("t1")
+
+	// Idle at trx BEGIN and END: the trx is counted (else c.Stats[-1] panics),
+	// and stats.TRX is the query, not idle time
+	idleTime := idle.Fixed(100 * time.Millisecond)
+	c := &client.Client{
+		DB:       db,
+		RunLevel: rl,
+		DoneChan: doneChan,
+		Statements: []*trx.Statement{
+			{Idle: idleTime},
+			{
+				Query:     "SELECT 1",
+				ResultSet: true,
+			},
+			{Idle: idleTime},
+		},
+		Data: []client.StatementData{
+			{TrxBoundary: trx.BEGIN},
+			{},
+			{TrxBoundary: trx.END},
+		},
+		Stats: []*stats.Trx{trxStats},
+		Iter:  2,
+	}
+
+	err = c.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Run(context.Background())
+
+	timeout := time.After(2 * time.Second)
+	var ret *client.Client
+	select {
+	case ret = <-doneChan:
+	case <-timeout:
+		t.Fatal("Client timeout after 2s")
+	}
+	if ret.Error.Err != nil {
+		t.Errorf("Client error: %v", ret.Error.Err)
+	}
+
+	s := trxStats.Swap()
+	if s.N[stats.READ] != 2 {
+		t.Errorf("got %d reads, expected 2", s.N[stats.READ])
+	}
+	if s.N[stats.TRX] != 2 {
+		t.Errorf("got %d trx, expected 2", s.N[stats.TRX])
+	}
+	if s.Max[stats.TRX] >= 100000 {
+		t.Errorf("max trx time %d us includes idle time, expected < 100000 us", s.Max[stats.TRX])
+	}
+}
+
+func TestClient_TimeoutReconnect(t *testing.T) {
+	if test.Build {
+		t.Skip("GitHub Actions build")
+	}
+
+	_, db, err := test.Connection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	doneChan := make(chan *client.Client, 1)
+	trxStats := stats.NewTrx("t1")
+
+	// Statement timeout closes the conn, so the client must reconnect even
+	// though the timeout error class is handled with continue
+	c := &client.Client{
+		DB:       db,
+		RunLevel: rl,
+		DoneChan: doneChan,
+		Statements: []*trx.Statement{
+			{
+				Query:     "SELECT SLEEP(1)",
+				ResultSet: true,
+				Timeout:   50 * time.Millisecond,
+			},
+		},
+		Data: []client.StatementData{
+			{
+				TrxBoundary: trx.BEGIN | trx.END,
+			},
+		},
+		Stats:         []*stats.Trx{trxStats},
+		Iter:          2,
+		ErrorHandling: map[uint16]byte{finch.ErrTimeout: finch.Econtinue | finch.Esilent},
+	}
+
+	err = c.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Run(context.Background())
+
+	timeout := time.After(2 * time.Second)
+	var ret *client.Client
+	select {
+	case ret = <-doneChan:
+	case <-timeout:
+		t.Fatal("Client timeout after 2s")
+	}
+	if ret.Error.Err != nil {
+		t.Errorf("Client error: %v", ret.Error.Err)
+	}
+
+	s := trxStats.Swap()
+	if _, timeouts := s.ErrorCount(); timeouts != 2 {
+		t.Errorf("got %d timeouts, expected 2", timeouts)
+	}
+	if s.N[stats.CONNECT] < 2 {
+		t.Errorf("got %d connects, expected reconnect after timeout", s.N[stats.CONNECT])
+	}
+}
+
+func TestClient_RetryTrx(t *testing.T) {
+	if test.Build {
+		t.Skip("GitHub Actions build")
+	}
+
+	_, db, err := test.Connection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	doneChan := make(chan *client.Client, 1)
+	trx1 := stats.NewTrx("t1")
+	trx2 := stats.NewTrx("t2")
+
+	// Two trx, second fails on its last statement: retry must execute only
+	// the second trx again from its first statement, Retries times per trx
+	c := &client.Client{
+		DB:       db,
+		RunLevel: rl,
+		DoneChan: doneChan,
+		Statements: []*trx.Statement{
+			{Query: "SELECT 1", ResultSet: true},                          // trx 1
+			{Query: "SELECT 2", ResultSet: true},                          // trx 2
+			{Query: "SELECT * FROM mysql.no_such_table", ResultSet: true}, // 1146
+		},
+		Data: []client.StatementData{
+			{TrxBoundary: trx.BEGIN | trx.END},
+			{TrxBoundary: trx.BEGIN},
+			{TrxBoundary: trx.END},
+		},
+		Stats:         []*stats.Trx{trx1, trx2},
+		Iter:          2,
+		ErrorHandling: map[uint16]byte{1146: finch.Eretry | finch.Econtinue | finch.Esilent},
+		Retries:       2,
+	}
+
+	err = c.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Run(context.Background())
+
+	timeout := time.After(2 * time.Second)
+	var ret *client.Client
+	select {
+	case ret = <-doneChan:
+	case <-timeout:
+		t.Fatal("Client timeout after 2s")
+	}
+	if ret.Error.Err != nil {
+		t.Errorf("Client error: %v", ret.Error.Err)
+	}
+
+	// Per iter: trx 1 once, trx 2 once plus 2 retries (3 x 2 statements)
+	s1 := trx1.Swap()
+	if s1.N[stats.READ] != 2 {
+		t.Errorf("trx 1: got %d reads, expected 2 (not retried)", s1.N[stats.READ])
+	}
+	s2 := trx2.Swap()
+	if s2.N[stats.READ] != 12 {
+		t.Errorf("trx 2: got %d reads, expected 12 (retried twice per iter)", s2.N[stats.READ])
+	}
+	if n, _ := s2.ErrorCount(); n != 6 {
+		t.Errorf("trx 2: got %d errors, expected 6", n)
+	}
+}
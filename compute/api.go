@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
@@ -16,6 +15,7 @@ import (
 	"github.com/square/finch"
 	"github.com/square/finch/config"
 	"github.com/square/finch/stats"
+	"github.com/square/finch/trx"
 )
 
 type API struct {
@@ -279,8 +279,8 @@ func (a *API) file(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Sending file %s to %s...", s.Trx[i].File, rc.name)
 
-	// Read file and send it to the client instance
-	bytes, err := ioutil.ReadFile(s.Trx[i].File)
+	// Read file, with includes expanded, and send it to the client instance
+	bytes, err := trx.Expand(s.Trx[i].File, s.Params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -110,6 +110,13 @@ func (c *Client) run(ctxFinch context.Context) error {
 	if err != nil {
 		return err
 	}
+	cfg.Lag = nil                   // runs only on the server
+	cfg.Hooks = nil                 // same
+	cfg.RemoveEvents()              // same
+	cfg.Stats.ServerMetrics = nil   // same
+	cfg.Stats.Digests = nil         // same
+	cfg.Stats.LockDiagnostics = nil // same
+	cfg.Stats.Assert = nil          // same
 
 	log.Printf("[%s] Booting", stageName)
 	local := stage.New(cfg, c.gds, stats)
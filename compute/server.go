@@ -9,12 +9,17 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"text/tabwriter"
 
+	h "github.com/dustin/go-humanize"
 	"github.com/rs/xid"
+	"gopkg.in/yaml.v2"
 
 	"github.com/square/finch"
 	"github.com/square/finch/config"
 	"github.com/square/finch/data"
+	"github.com/square/finch/hook"
+	"github.com/square/finch/results"
 	"github.com/square/finch/stage"
 	"github.com/square/finch/stats"
 )
@@ -22,12 +27,16 @@ import (
 // Server coordinates instances: the local and any remotes. Server implements
 // Compute so server.Server (the Finch core server) can run as a client or server.
 type Server struct {
+	Results *results.Bundle // --results, if set
+
 	api  *API   // handles remote compute (rc)
 	name string // defaults to "local"
 	test bool
 	// --
-	gds *data.Scope // global data scope
-	cfg config.Stage
+	gds     *data.Scope // global data scope
+	cfg     config.Stage
+	summary *stats.Summary // current matrix, if any
+	failed  int            // stats.assert rules failed in all stages
 }
 
 type ack struct {
@@ -48,24 +57,103 @@ func NewServer(name, addr string, test bool) *Server {
 }
 
 func (s *Server) Run(ctxFinch context.Context, stages []config.Stage) error {
-	for _, cfg := range stages {
+	for i, cfg := range stages {
 		// cd dir of config file so relative file paths in config work
 		if err := os.Chdir(filepath.Dir(cfg.File)); err != nil {
 			return err
 		}
 
+		if s.Results != nil {
+			if err := s.Results.Stage(ctxFinch, &cfg); err != nil {
+				return fmt.Errorf("results: %s", err)
+			}
+		}
+
+		if cfg.MatrixNo == 1 {
+			s.summary = stats.NewSummary(config.MatrixKeys(cfg.Matrix))
+		}
+
 		if err := s.run(ctxFinch, cfg); err != nil {
 			return err
 		}
 
+		// Print matrix summary after last stage in matrix, or on CTRL-C
+		// to summarize the stages that ran
+		if cfg.MatrixNo > 0 && (i == len(stages)-1 || stages[i+1].MatrixNo <= 1 || ctxFinch.Err() != nil) {
+			s.printSummary(cfg)
+		}
+
 		if ctxFinch.Err() != nil {
 			finch.Debug("finch terminated")
-			return nil
+			return s.assertErr()
 		}
 	}
+	return s.assertErr()
+}
+
+// assertErr returns an error if any stats.assert rules failed in any stage,
+// which makes Finch exit non-zero.
+func (s *Server) assertErr() error {
+	if s.failed > 0 {
+		return fmt.Errorf("%d stats.assert rules failed", s.failed)
+	}
 	return nil
 }
 
+// printAssert prints the stats.assert results for a stage and returns the
+// number of rules that failed.
+func printAssert(stageName string, res []stats.AssertResult) int {
+	if len(res) == 0 {
+		return 0
+	}
+	failed := 0
+	fmt.Printf("#\n# Assert: %s\n#\n", stageName)
+	tw := tabwriter.NewWriter(os.Stdout, 1, 0, 2, ' ', 0)
+	for _, r := range res {
+		result := "PASS"
+		if !r.Pass {
+			result = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t(%s)\n", result, r.Rule, h.CommafWithDigits(r.Value, 1))
+	}
+	tw.Flush()
+	fmt.Printf("%d of %d rules failed\n\n", failed, len(res))
+	return failed
+}
+
+// printSummary prints the summary of all stages in a matrix.
+func (s *Server) printSummary(cfg config.Stage) {
+	if s.summary == nil || s.summary.Len() == 0 {
+		return // stats disabled or --test
+	}
+	fmt.Printf("#\n# Matrix summary: %s\n#\n", filepath.Base(cfg.File))
+	s.summary.Print(os.Stdout)
+	fmt.Println()
+	s.summary = nil
+}
+
+// htmlOpts sets the html reporter options "stage" and "config" (redacted YAML)
+// because the reporter prints the stage config. The report map is copied
+// because it's shared with other stages that inherit it from _all.yaml.
+func htmlOpts(cfg *config.Stage) {
+	opts := map[string]string{"stage": cfg.Name}
+	if bytes, err := yaml.Marshal(map[string]config.Stage{"stage": results.Redact(*cfg)}); err != nil {
+		log.Printf("Error marshaling stage config for html reporter: %s", err)
+	} else {
+		opts["config"] = string(bytes)
+	}
+	for k, v := range cfg.Stats.Report["html"] {
+		opts[k] = v
+	}
+	report := make(map[string]map[string]string, len(cfg.Stats.Report))
+	for name, v := range cfg.Stats.Report {
+		report[name] = v
+	}
+	report["html"] = opts
+	cfg.Stats.Report = report
+}
+
 // Run runs all the stages on all the instances (local and remote).
 func (s *Server) run(ctxFinch context.Context, cfg config.Stage) error {
 	var err error
@@ -94,12 +182,26 @@ func (s *Server) run(ctxFinch context.Context, cfg config.Stage) error {
 	}
 
 	if !config.True(cfg.Stats.Disable) {
+		if _, ok := cfg.Stats.Report["html"]; ok {
+			htmlOpts(&cfg)
+		}
 		m.stats, err = stats.NewCollector(cfg.Stats, s.name, nInstances)
 		if err != nil {
 			return err
 		}
 	}
 
+	// Hooks (config.stage.hooks), if any, run only on the server and not with
+	// --test because they change MySQL or the system
+	var hooks *hook.Runner
+	if cfg.Hooks != nil && !s.test {
+		hooks = hook.NewRunner(stageName, *cfg.Hooks, cfg.MySQL)
+		defer hooks.Close()
+		if err := hooks.Run(ctxFinch, hook.BEFORE_STAGE); err != nil {
+			return err
+		}
+	}
+
 	s.gds.Reset() // keep data global and stage data, delete the rest
 
 	// Create and boot local instance first because if this doesn't work,
@@ -112,6 +214,10 @@ func (s *Server) run(ctxFinch context.Context, cfg config.Stage) error {
 			return err
 		}
 		m.bootChan <- ack{name: s.name} // must ack local, too
+	} else if cfg.Lag != nil {
+		// Lag probe runs only on the local instance (see stage.Prepare), and
+		// remote instances don't run it (see compute.Client)
+		log.Printf("[%s] WARNING: compute.disable-local=true, ignoring lag", stageName)
 	}
 
 	// Set stage in API to trigger remote instances to boot
@@ -157,12 +263,29 @@ func (s *Server) run(ctxFinch context.Context, cfg config.Stage) error {
 	// Run stage
 	// ----------------------------------------------------------------------
 
+	// ctxRun is canceled if an on-interval hook aborts the stage
+	ctxRun, cancelRun := context.WithCancel(ctxFinch)
+	defer cancelRun()
+	if hooks != nil {
+		if err := hooks.Run(ctxFinch, hook.BEFORE_RUN); err != nil {
+			return err
+		}
+		if len(cfg.Hooks.OnInterval) > 0 {
+			if m.stats == nil || local == nil {
+				log.Printf("[%s] WARNING: stats disabled or compute.disable-local=true, ignoring on-interval hooks", stageName)
+			} else {
+				hooks.Start(ctxRun, cancelRun)
+				m.stats.AddSampler(hooks)
+			}
+		}
+	}
+
 	finch.Debug("run %s", stageName)
 	close(m.runChan) // signal remotes to run
 
 	if local != nil { // start local instance
 		go func() {
-			local.Run(ctxFinch)
+			local.Run(ctxRun)
 			m.doneChan <- ack{name: s.name}
 		}()
 	}
@@ -182,7 +305,7 @@ func (s *Server) run(ctxFinch context.Context, cfg config.Stage) error {
 					log.Printf("%d/%d instances running", running, nInstances)
 				}
 			}
-		case <-ctxFinch.Done():
+		case <-ctxRun.Done():
 			// Signal remote instances to stop early and (maybe) send finals stats
 			if s.api != nil {
 				s.api.Stage(nil)
@@ -190,5 +313,24 @@ func (s *Server) run(ctxFinch context.Context, cfg config.Stage) error {
 		}
 	}
 
+	if s.summary != nil && m.stats != nil {
+		s.summary.Add(cfg.MatrixParams, m.stats.Summary())
+	}
+
+	if m.stats != nil {
+		s.failed += printAssert(stageName, m.stats.Assert())
+	}
+
+	if hooks != nil {
+		if err := hooks.Stop(); err != nil {
+			return err
+		}
+		if ctxFinch.Err() == nil { // not on CTRL-C
+			if err := hooks.Run(ctxFinch, hook.AFTER_STAGE); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
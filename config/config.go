@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -58,7 +59,7 @@ func Load(stageFiles []string, kvparams []string, dsn, db string) ([]Stage, erro
 		params[f[0]] = f[1]
 	}
 
-	for n, fileName := range stageFiles {
+	for _, fileName := range stageFiles {
 		// Load base file (_all.yaml) once for the dir, if it exists
 		dir := filepath.Dir(fileName)
 		b, ok := base[dir]
@@ -104,47 +105,125 @@ func Load(stageFiles []string, kvparams []string, dsn, db string) ([]Stage, erro
 		if err != nil {
 			return nil, err
 		}
-		f := &stageFile{Stage: Stage{
-			File: absFile,
-			N:    uint(n + 1),
-		}}
-		if err := yaml.UnmarshalStrict(bytes, f); err != nil {
+		var m stageFile
+		if err := yaml.UnmarshalStrict(bytes, &m); err != nil {
 			return nil, fmt.Errorf("cannot decode YAML in %s: %s", fileName, err)
 		}
+		matrix, err := Expand(m.Stage.Matrix)
+		if err != nil {
+			return nil, fmt.Errorf("%s invalid: %s", fileName, err)
+		}
 
-		// Set stage with defaults (base)
-		f.Stage.With(b)
+		// One stage, or one stage per matrix combination. Each one is decoded
+		// again to get a new copy that's interpolated with its own params.
+		for i, mp := range matrix {
+			f := &stageFile{Stage: Stage{
+				File: absFile,
+				N:    uint(len(stages) + 1),
+			}}
+			if err := yaml.UnmarshalStrict(bytes, f); err != nil {
+				return nil, fmt.Errorf("cannot decode YAML in %s: %s", fileName, err)
+			}
 
-		// --dsn and --database on command line override config files
-		if dsn != "" {
-			f.Stage.MySQL.DSN = dsn
-		}
-		if db != "" {
-			f.Stage.MySQL.Db = db
-		}
+			// Set stage with defaults (base)
+			f.Stage.With(b)
 
-		// interpolate $vars -> values (see Vars func below)
-		if err := f.Stage.Vars(); err != nil {
-			return nil, fmt.Errorf("in %s: %s", fileName, err)
-		}
+			// --dsn and --database on command line override config files
+			if dsn != "" {
+				f.Stage.MySQL.DSN = dsn
+			}
+			if db != "" {
+				f.Stage.MySQL.Db = db
+			}
 
-		// Chdir to confit file so relative trx file paths in the config work,
-		// e.g. "trx.file: trx/foo.sql" where trx/ is relative to the dir where
-		// the config file is located.
-		os.Chdir(filepath.Dir(fileName))
+			// Matrix values override params in the stage and base files
+			if mp != nil {
+				if f.Stage.Params == nil {
+					f.Stage.Params = map[string]string{}
+				}
+				for k, v := range mp {
+					f.Stage.Params[k] = v
+				}
+				f.Stage.MatrixParams = mp
+				f.Stage.MatrixNo = uint(i + 1)
+			}
 
-		// Validate config now that it's final (interpolated vars and chdir)
-		if err := f.Stage.Validate(); err != nil {
-			return nil, fmt.Errorf("%s invalid: %s", fileName, err)
-		}
-		stages = append(stages, f.Stage)
-		finch.Debug("%+v", f.Stage)
+			// interpolate $vars -> values (see Vars func below)
+			if err := f.Stage.Vars(); err != nil {
+				return nil, fmt.Errorf("in %s: %s", fileName, err)
+			}
 
-		os.Chdir(cwd)
+			// Chdir to confit file so relative trx file paths in the config work,
+			// e.g. "trx.file: trx/foo.sql" where trx/ is relative to the dir where
+			// the config file is located.
+			os.Chdir(filepath.Dir(fileName))
+
+			// Validate config now that it's final (interpolated vars and chdir)
+			if err := f.Stage.Validate(); err != nil {
+				return nil, fmt.Errorf("%s invalid: %s", fileName, err)
+			}
+			if mp != nil {
+				f.Stage.Name += " " + MatrixString(f.Stage.Matrix, mp)
+			}
+			stages = append(stages, f.Stage)
+			finch.Debug("%+v", f.Stage)
+
+			os.Chdir(cwd)
+		}
 	}
 	return stages, nil
 }
 
+// Expand returns the param values of every combination of matrix values, or
+// one nil map if the matrix is empty (no matrix, one stage). Params are sorted
+// by name, and the first param varies the slowest; values are in the order listed.
+// For example, {a: [1,2], b: [x,y]} returns a=1 b=x, a=1 b=y, a=2 b=x, a=2 b=y.
+func Expand(matrix map[string][]string) ([]map[string]string, error) {
+	if len(matrix) == 0 {
+		return []map[string]string{nil}, nil
+	}
+	params := MatrixKeys(matrix)
+	all := []map[string]string{{}}
+	for _, k := range params {
+		if len(matrix[k]) == 0 {
+			return nil, fmt.Errorf("matrix.%s has no values", k)
+		}
+		next := make([]map[string]string, 0, len(all)*len(matrix[k]))
+		for _, prev := range all {
+			for _, v := range matrix[k] {
+				mp := make(map[string]string, len(prev)+1)
+				for pk, pv := range prev {
+					mp[pk] = pv
+				}
+				mp[k] = v
+				next = append(next, mp)
+			}
+		}
+		all = next
+	}
+	return all, nil
+}
+
+// MatrixKeys returns the matrix param names sorted.
+func MatrixKeys(matrix map[string][]string) []string {
+	keys := make([]string, 0, len(matrix))
+	for k := range matrix {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MatrixString returns the matrix param values like "a=1 b=x".
+func MatrixString(matrix map[string][]string, mp map[string]string) string {
+	keys := MatrixKeys(matrix)
+	kv := make([]string, len(keys))
+	for i, k := range keys {
+		kv[i] = k + "=" + mp[k]
+	}
+	return strings.Join(kv, " ")
+}
+
 func read(filePath string) ([]byte, error) {
 	finch.Debug("read %s", filePath)
 	file, err := filepath.Abs(filePath)
@@ -202,7 +281,7 @@ var varRE = []*regexp.Regexp{
 	regexp.MustCompile(`\${([^}]+)}`),  // ${param.foo} for "hello${param.foo}bar"
 	regexp.MustCompile(`\$([^\s"']+)`), // $param.foo for standalone value
 }
-var reHumanNumber = regexp.MustCompile(`([\d,]*\d+(?i:[MKGBI]*))`) // 1M or 1,000,000 -> 1000000
+var reHumanNumber = regexp.MustCompile(`([\d,]*\d+(?i:[MKGBI]*))\b`) // 1M or 1,000,000 -> 1000000, but not 5ms
 var reAllDigits = regexp.MustCompile(`^\d+$`)
 
 // Vars changes $params.foo and $FOO to param values and environment variable
@@ -4,9 +4,11 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-test/deep"
 
+	"github.com/square/finch"
 	"github.com/square/finch/config"
 )
 
@@ -24,6 +26,262 @@ func TestValidate_Stage(t *testing.T) {
 	}
 }
 
+func TestValidate_WorkloadMySQL(t *testing.T) {
+	// workload[].mysql inherits stage.mysql except dsn and the address
+	c := config.Stage{
+		Name: "test",
+		MySQL: config.MySQL{
+			DSN:      "finch@tcp(primary)/db",
+			Hostname: "primary",
+			Username: "finch",
+			Password: "amazing",
+		},
+		Trx: []config.Trx{
+			{Name: "trx.sql", File: "../test/config/b1/trx.sql"},
+		},
+		Workload: []config.ClientGroup{
+			{Trx: []string{"trx.sql"}},
+			{Trx: []string{"trx.sql"}, MySQL: &config.MySQL{Socket: "/tmp/replica.sock"}},
+		},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Workload[0].MySQL != nil {
+		t.Errorf("workload[0].mysql set, expected nil")
+	}
+	expect := &config.MySQL{
+		Socket:   "/tmp/replica.sock",
+		Username: "finch",
+		Password: "amazing",
+	}
+	if diff := deep.Equal(c.Workload[1].MySQL, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestValidate_Lag(t *testing.T) {
+	// lag.replica inherits stage.mysql like workload[].mysql, and defaults are set
+	c := config.Stage{
+		Name: "test",
+		MySQL: config.MySQL{
+			Hostname: "primary",
+			Username: "finch",
+		},
+		Trx: []config.Trx{
+			{Name: "trx.sql", File: "../test/config/b1/trx.sql"},
+		},
+		Lag: &config.Lag{
+			Replica: config.MySQL{Hostname: "replica"},
+		},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	expect := &config.Lag{
+		Freq:    "100ms",
+		Replica: config.MySQL{Hostname: "replica", Username: "finch"},
+		Table:   "finch.heartbeat",
+	}
+	if diff := deep.Equal(c.Lag, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	c.Lag = &config.Lag{Table: "heartbeat"}
+	if err := c.Validate(); err == nil {
+		t.Error("no error without replica address")
+	}
+	c.Lag.Replica.Hostname = "replica"
+	if err := c.Validate(); err == nil {
+		t.Error("no error for table without database")
+	}
+}
+
+func TestValidate_ServerMetrics(t *testing.T) {
+	// server-metrics: {} uses the defaults; stages inherit it from _all.yaml
+	b := config.Base{Stats: config.Stats{ServerMetrics: &config.ServerMetrics{}}}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	expect := &config.ServerMetrics{
+		Status:        []string{"Innodb_rows_read", "Innodb_buffer_pool_reads", "Threads_running"},
+		InnoDBMetrics: []string{"trx_rseg_history_len"},
+	}
+	if diff := deep.Equal(b.Stats.ServerMetrics, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	var c config.Stage
+	c.With(b)
+	if diff := deep.Equal(c.Stats.ServerMetrics, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	c.Stats.ServerMetrics = &config.ServerMetrics{Status: []string{"Threads_running", " "}}
+	if err := c.Stats.Validate(); err == nil {
+		t.Error("no error for empty metric name")
+	}
+}
+
+func TestParseAssert(t *testing.T) {
+	got, err := config.ParseAssert("P99 < 5ms for trx read-only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := config.Assert{
+		Rule:   "P99 < 5ms for trx read-only",
+		Metric: "p99",
+		Op:     "<",
+		Value:  5000, // microseconds
+		Trx:    "read-only",
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	got, err = config.ParseAssert("qps >= 10000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != 10000 || got.Trx != "" {
+		t.Errorf("got %+v, expected value 10000 and no trx", got)
+	}
+
+	// Durations are microseconds, and human numbers are not durations:
+	// 1m is one minute, not one million
+	for rule, v := range map[string]float64{
+		"p99 < 1m":      60000000,
+		"p99 < 5ms":     5000,
+		"qps >= 1k":     1000,
+		"qps >= 10,000": 10000,
+	} {
+		got, err = config.ParseAssert(rule)
+		if err != nil {
+			t.Errorf("%s: %s", rule, err)
+			continue
+		}
+		if got.Value != v {
+			t.Errorf("%s: got value %f, expected %f", rule, got.Value, v)
+		}
+	}
+
+	// Same after interpolation (Stats.Vars), which must not convert 1m to 1000000
+	st := config.Stats{Assert: []string{"p99 < 1m", "qps >= 1k"}}
+	if err := st.Vars(nil); err != nil {
+		t.Fatal(err)
+	}
+	if st.Assert[0] != "p99 < 1m" || st.Assert[1] != "qps >= 1k" {
+		t.Errorf("assert rules changed by Vars: %v", st.Assert)
+	}
+
+	for _, rule := range []string{
+		"errors = 0",           // invalid op
+		"qps >= fast",          // invalid value
+		"p99 < 5ms for read",   // missing "trx"
+		"p99 < 5ms trx foo",    // missing "for"
+		"< 5ms",                // missing metric
+		"99p < 5ms",            // invalid metric
+		"errors == 0 for trx ", // missing trx name
+	} {
+		if _, err := config.ParseAssert(rule); err == nil {
+			t.Errorf("no error for invalid rule: %s", rule)
+		}
+	}
+}
+
+func TestValidate_AssertStatsDisabled(t *testing.T) {
+	stage := func(disable bool) config.Stage {
+		return config.Stage{
+			Name: "s1",
+			Trx:  []config.Trx{{File: "../test/trx/001.sql"}},
+			Stats: config.Stats{
+				Disable: &disable,
+				Assert:  []string{"errors == 0"},
+			},
+		}
+	}
+	c := stage(true)
+	if err := c.Validate(); err == nil {
+		t.Error("no error for stats.assert with stats disabled")
+	}
+	c = stage(false)
+	if err := c.Validate(); err != nil {
+		t.Errorf("got error %v, expected nil with stats enabled", err)
+	}
+}
+
+func TestValidate_Hooks(t *testing.T) {
+	h := config.Hooks{
+		BeforeStage: []config.Hook{{SQL: "FLUSH STATUS"}, {Exec: "sync", Timeout: "5s"}},
+	}
+	if err := h.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	for _, bad := range []config.Hook{
+		{},                                  // neither sql nor exec
+		{SQL: "FLUSH STATUS", Exec: "sync"}, // both
+		{Exec: "sync", Timeout: "5"},        // invalid duration
+	} {
+		h := config.Hooks{AfterStage: []config.Hook{bad}}
+		if err := h.Validate(); err == nil {
+			t.Errorf("no error for invalid hook: %+v", bad)
+		}
+	}
+
+	// Vars sets the hooks in place
+	h = config.Hooks{OnInterval: []config.Hook{{Exec: "snapshot $params.dir"}}}
+	if err := h.Vars(map[string]string{"dir": "/tmp"}); err != nil {
+		t.Fatal(err)
+	}
+	if h.OnInterval[0].Exec != "snapshot /tmp" {
+		t.Errorf("got exec %q, expected \"snapshot /tmp\"", h.OnInterval[0].Exec)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	for _, bad := range []config.Event{
+		{Trx: "a"},                        // neither at nor every
+		{At: "1s", Every: "1s", Trx: "a"}, // both
+		{At: "-1s", Trx: "a"},             // negative
+		{Every: "0", Trx: "a"},            // zero
+		{At: "1s"},                        // no trx
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("no error for invalid event: %+v", bad)
+		}
+	}
+
+	c := config.Stage{
+		Trx: []config.Trx{{Name: "a"}, {Name: "ddl"}, {Name: "purge"}},
+		Events: []config.Event{
+			{At: "0s", Trx: "ddl"},
+			{Every: "30s", Trx: "purge"},
+		},
+		Workload: []config.ClientGroup{{Trx: []string{"a", "purge"}}},
+	}
+	for _, e := range c.Events {
+		if err := e.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// purge is in the workload, too, so only ddl is event-only
+	expect := map[string]bool{"ddl": true}
+	if diff := deep.Equal(c.EventTrx(), expect); diff != nil {
+		t.Error(diff)
+	}
+
+	c.RemoveEvents()
+	if c.Events != nil {
+		t.Errorf("events not removed: %+v", c.Events)
+	}
+	expectTrx := []config.Trx{{Name: "a"}, {Name: "purge"}}
+	if diff := deep.Equal(c.Trx, expectTrx); diff != nil {
+		t.Error(diff)
+	}
+}
+
 func TestVars(t *testing.T) {
 	params := map[string]string{
 		"foo": "bar",
@@ -50,6 +308,7 @@ func TestVars(t *testing.T) {
 		{"rows: 1,000", "rows: 1000", true},
 		{"size: 1GiB", "size: 1073741824", true},
 		{"(1, 2, 'foo')", "(1, 2, 'foo')", true},
+		{"idle: 5ms", "idle: 5ms", true}, // time duration, not 5M
 		// numbers=false
 		{"db.abd6b.us-east-1.rds.amazonaws.com", "db.abd6b.us-east-1.rds.amazonaws.com", false},
 	}
@@ -104,3 +363,203 @@ func TestLoadWithBase(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestStage_WithStats(t *testing.T) {
+	// Base stats sections are inherited only if the stage doesn't set them
+	b := config.Base{
+		Stats: config.Stats{
+			ServerMetrics: &config.ServerMetrics{Status: []string{"Threads_running"}},
+		},
+	}
+	c := config.Stage{}
+	c.With(b)
+	if c.Stats.ServerMetrics == nil || len(c.Stats.ServerMetrics.Status) != 1 {
+		t.Errorf("stats.server-metrics not inherited: %+v", c.Stats.ServerMetrics)
+	}
+
+	c = config.Stage{
+		Stats: config.Stats{
+			ServerMetrics: &config.ServerMetrics{Status: []string{"Questions", "Com_select"}},
+		},
+	}
+	c.With(b)
+	if c.Stats.ServerMetrics == nil || len(c.Stats.ServerMetrics.Status) != 2 {
+		t.Errorf("stage stats.server-metrics overwritten by base: %+v", c.Stats.ServerMetrics)
+	}
+
+	// Digests
+	truncate := true
+	b = config.Base{
+		Stats: config.Stats{Digests: &config.Digests{Truncate: &truncate}},
+	}
+	noTruncate := false
+	c = config.Stage{
+		Stats: config.Stats{Digests: &config.Digests{Truncate: &noTruncate}},
+	}
+	c.With(b)
+	if c.Stats.Digests == nil || c.Stats.Digests.Truncate == nil || *c.Stats.Digests.Truncate {
+		t.Errorf("stage stats.digests overwritten by base: %+v", c.Stats.Digests)
+	}
+	c = config.Stage{}
+	c.With(b)
+	if c.Stats.Digests == nil {
+		t.Error("stats.digests not inherited")
+	}
+
+	// Lock diagnostics
+	b = config.Base{
+		Stats: config.Stats{LockDiagnostics: &config.LockDiagnostics{Freq: "10s"}},
+	}
+	c = config.Stage{
+		Stats: config.Stats{LockDiagnostics: &config.LockDiagnostics{Freq: "1s"}},
+	}
+	c.With(b)
+	if c.Stats.LockDiagnostics == nil || c.Stats.LockDiagnostics.Freq != "1s" {
+		t.Errorf("stage stats.lock-diagnostics overwritten by base: %+v", c.Stats.LockDiagnostics)
+	}
+	c = config.Stage{}
+	c.With(b)
+	if c.Stats.LockDiagnostics == nil || c.Stats.LockDiagnostics.Freq != "10s" {
+		t.Errorf("stats.lock-diagnostics not inherited: %+v", c.Stats.LockDiagnostics)
+	}
+}
+
+func TestErrors_Flags(t *testing.T) {
+	c := config.Errors{
+		"1062":      "continue",
+		"deadlock":  "rollback, continue",
+		"1836":      "silent,reconnect",
+		"read-only": "abort", // 1836 explicit takes precedence
+		"timeout":   "reconnect, silent",
+		"3024":      "continue", // explicit takes precedence
+	}
+	got, err := c.Flags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[uint16]byte{
+		1062: finch.Econtinue,
+		1213: finch.Erollback | finch.Econtinue,
+		1290: finch.Eabort,
+		1836: finch.Esilent | finch.Ereconnect,
+		3024: finch.Econtinue,
+
+		finch.ErrTimeout: finch.Ereconnect | finch.Esilent,
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	invalid := []config.Errors{
+		{"1062": "ignore"},         // invalid action
+		{"dup": "continue"},        // invalid class
+		{"99999": "continue"},      // not uint16
+		{"1062": "abort,continue"}, // abort + other
+		{"timeout": "continue"},    // statement timeout always reconnects
+		{"timeout": "retry"},       // same
+		{"65535": "continue"},      // finch.ErrTimeout is not a MySQL error code
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("no error for %v, expected validation error", c)
+		}
+	}
+}
+
+func TestSession_SQL(t *testing.T) {
+	c := config.Session{
+		"transaction_isolation":    "READ-COMMITTED",
+		"innodb_lock_wait_timeout": "5",
+		"autocommit":               "off",
+		"sql_mode":                 "it's",
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	expect := "SET SESSION autocommit=off, innodb_lock_wait_timeout=5, sql_mode='it\\'s', transaction_isolation='READ-COMMITTED'"
+	if got := c.SQL(); got != expect {
+		t.Errorf("got %s, expected %s", got, expect)
+	}
+	if got := (config.Session{}).SQL(); got != "" {
+		t.Errorf("got %s, expected empty string", got)
+	}
+
+	c = config.Session{"tx_isolation; DROP TABLE t": "1"}
+	if err := c.Validate(); err == nil {
+		t.Error("no error for invalid variable name")
+	}
+}
+
+func TestParseConnection(t *testing.T) {
+	valid := map[string]config.Connection{
+		"":        {},
+		"client":  {},
+		"trx":     {Trx: true},
+		"iter":    {Iter: 1},
+		"iter 10": {Iter: 10},
+		"time 5s": {Time: 5 * time.Second},
+	}
+	for s, expect := range valid {
+		got, err := config.ParseConnection(s)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			continue
+		}
+		if got != expect {
+			t.Errorf("%s: got %+v, expected %+v", s, got, expect)
+		}
+	}
+
+	invalid := []string{"conn", "trx 2", "iter 0", "iter x", "time", "time 0s", "time 5"}
+	for _, s := range invalid {
+		if _, err := config.ParseConnection(s); err == nil {
+			t.Errorf("no error for '%s', expected error", s)
+		}
+	}
+}
+
+func TestLoad_Matrix(t *testing.T) {
+	stages, err := config.Load([]string{"../test/config/matrix/stage.yaml"}, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type stage struct {
+		N        uint
+		Name     string
+		Clients  string
+		QPS      string
+		MatrixNo uint
+		Params   map[string]string
+	}
+	got := make([]stage, len(stages))
+	for i, s := range stages {
+		got[i] = stage{s.N, s.Name, s.Workload[0].Clients, s.QPS, s.MatrixNo, s.MatrixParams}
+	}
+	// Params are sorted, so "clients" varies the slowest
+	expect := []stage{
+		{1, "m clients=1 qps=100", "1", "100", 1, map[string]string{"clients": "1", "qps": "100"}},
+		{2, "m clients=1 qps=200", "1", "200", 2, map[string]string{"clients": "1", "qps": "200"}},
+		{3, "m clients=2 qps=100", "2", "100", 3, map[string]string{"clients": "2", "qps": "100"}},
+		{4, "m clients=2 qps=200", "2", "200", 4, map[string]string{"clients": "2", "qps": "200"}},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// No values is an error
+	_, err = config.Expand(map[string][]string{"clients": {}})
+	if err == nil {
+		t.Error("no error for matrix param with no values, expected an error")
+	}
+}
+
+func TestStats_Throughput(t *testing.T) {
+	c := config.Stats{Report: map[string]map[string]string{"stdout": {}, "csv": {"throughput": "false"}}}
+	if c.Throughput() {
+		t.Error("Throughput true, expected false when no reporter prints throughput")
+	}
+	c.Report["csv"]["throughput"] = "true"
+	if !c.Throughput() {
+		t.Error("Throughput false, expected true when csv reporter prints throughput")
+	}
+}
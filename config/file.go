@@ -8,8 +8,17 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	human "github.com/dustin/go-humanize"
 
 	"github.com/square/finch"
+	"github.com/square/finch/idle"
 )
 
 // Base represents a base config file: _all.yaml. If it exists, it applies to
@@ -33,21 +42,31 @@ func (c *Base) Validate() error {
 // Stage represents one stage config file. The stage config overwrites any base
 // config (_all.yaml).
 type Stage struct {
-	Compute  Compute           `yaml:"compute,omitempty"`
-	Disable  bool              `yaml:"disable"`
-	File     string            `yaml:"-"`
-	Id       string            `yaml:"-"`
-	Name     string            `yaml:"name"`
-	MySQL    MySQL             `yaml:"mysql,omitempty"`
-	N        uint              `yaml:"-"`
-	Params   map[string]string `yaml:"params,omitempty"`
-	QPS      string            `yaml:"qps,omitempty"` // uint
-	Runtime  string            `yaml:"runtime,omitempty"`
-	Stats    Stats             `yaml:"stats,omitempty"`
-	TPS      string            `yaml:"tps,omitempty"` // uint
-	Test     bool              `yaml:"-"`
-	Trx      []Trx             `yaml:"trx,omitempty"`
-	Workload []ClientGroup     `yaml:"workload,omitempty"`
+	Compute  Compute             `yaml:"compute,omitempty"`
+	Disable  bool                `yaml:"disable"`
+	Errors   Errors              `yaml:"errors,omitempty"`
+	Events   []Event             `yaml:"events,omitempty"`
+	File     string              `yaml:"-"`
+	Hooks    *Hooks              `yaml:"hooks,omitempty"`
+	Id       string              `yaml:"-"`
+	Lag      *Lag                `yaml:"lag,omitempty"`
+	Matrix   map[string][]string `yaml:"matrix,omitempty"`
+	Name     string              `yaml:"name"`
+	MySQL    MySQL               `yaml:"mysql,omitempty"`
+	N        uint                `yaml:"-"`
+	Params   map[string]string   `yaml:"params,omitempty"`
+	QPS      string              `yaml:"qps,omitempty"` // uint
+	Runtime  string              `yaml:"runtime,omitempty"`
+	Stats    Stats               `yaml:"stats,omitempty"`
+	TPS      string              `yaml:"tps,omitempty"` // uint
+	Test     bool                `yaml:"-"`
+	Trx      []Trx               `yaml:"trx,omitempty"`
+	Workload []ClientGroup       `yaml:"workload,omitempty"`
+
+	// Set by Load when the stage is expanded from Matrix: the param values of
+	// this stage, and its number in the matrix (1..N)
+	MatrixParams map[string]string `yaml:"-"`
+	MatrixNo     uint              `yaml:"-"`
 }
 
 func (c *Stage) With(b Base) {
@@ -78,6 +97,21 @@ func (c *Stage) With(b Base) {
 			}
 		}
 	}
+	if len(c.Stats.Assert) == 0 && len(b.Stats.Assert) > 0 {
+		c.Stats.Assert = append([]string{}, b.Stats.Assert...)
+	}
+	if c.Stats.Digests == nil && b.Stats.Digests != nil {
+		c.Stats.Digests = &Digests{Truncate: setBool(nil, b.Stats.Digests.Truncate)}
+	}
+	if c.Stats.LockDiagnostics == nil && b.Stats.LockDiagnostics != nil {
+		c.Stats.LockDiagnostics = &LockDiagnostics{Freq: b.Stats.LockDiagnostics.Freq}
+	}
+	if c.Stats.ServerMetrics == nil && b.Stats.ServerMetrics != nil {
+		c.Stats.ServerMetrics = &ServerMetrics{
+			Status:        append([]string{}, b.Stats.ServerMetrics.Status...),
+			InnoDBMetrics: append([]string{}, b.Stats.ServerMetrics.InnoDBMetrics...),
+		}
+	}
 
 }
 
@@ -112,12 +146,30 @@ func (c *Stage) Vars() error {
 	if err := c.Compute.Vars(c.Params); err != nil {
 		return fmt.Errorf("in compute: %s", err)
 	}
+	if err := c.Errors.Vars(c.Params); err != nil {
+		return fmt.Errorf("in errors: %s", err)
+	}
 	if err := c.MySQL.Vars(c.Params); err != nil {
 		return fmt.Errorf("in mysql: %s", err)
 	}
 	if err := c.Stats.Vars(c.Params); err != nil {
 		return fmt.Errorf("in stats: %s", err)
 	}
+	if c.Lag != nil {
+		if err := c.Lag.Vars(c.Params); err != nil {
+			return fmt.Errorf("in lag: %s", err)
+		}
+	}
+	if c.Hooks != nil {
+		if err := c.Hooks.Vars(c.Params); err != nil {
+			return fmt.Errorf("in hooks: %s", err)
+		}
+	}
+	for i := range c.Events {
+		if err := c.Events[i].Vars(c.Params); err != nil {
+			return fmt.Errorf("in events[%d]: %s", i, err)
+		}
+	}
 	for i := range c.Trx {
 		if err := c.Trx[i].Vars(c.Params); err != nil {
 			return fmt.Errorf("in trx: %s", err)
@@ -194,6 +246,9 @@ func (c *Stage) Validate() error {
 	withTrx := map[int]int{}
 	withoutTrx := map[int]int{}
 	for i := range c.Workload {
+		if c.Workload[i].MySQL != nil {
+			c.Workload[i].MySQL.WithTarget(c.MySQL)
+		}
 		if err := c.Workload[i].Validate(c.Trx); err != nil {
 			return err
 		}
@@ -240,6 +295,10 @@ func (c *Stage) Validate() error {
 		return fmt.Errorf("tps: '%s' is not an integer: %s", c.TPS, err)
 	}
 
+	if err := c.Errors.Validate(); err != nil {
+		return fmt.Errorf("%s.errors: %s", c.Name, err)
+	}
+
 	if err := c.MySQL.Validate(); err != nil {
 		return err
 	}
@@ -251,6 +310,48 @@ func (c *Stage) Validate() error {
 	if err := c.Stats.Validate(); err != nil {
 		return err
 	}
+	if len(c.Stats.Assert) > 0 && True(c.Stats.Disable) {
+		return fmt.Errorf("%s.stats.assert requires stats, but %s.stats.disable = true; enable stats or remove the assert rules", c.Name, c.Name)
+	}
+ASSERT:
+	for _, rule := range c.Stats.Assert {
+		a, _ := ParseAssert(rule) // already validated
+		if a.Trx == "" {
+			continue
+		}
+		for k := range c.Trx {
+			if a.Trx == c.Trx[k].Name {
+				continue ASSERT
+			}
+		}
+		return fmt.Errorf("%s.stats.assert: %s: trx '%s' not defined in %s.trx", c.Name, rule, a.Trx, c.Name)
+	}
+
+	if c.Lag != nil {
+		c.Lag.Replica.WithTarget(c.MySQL)
+		if err := c.Lag.Validate(); err != nil {
+			return fmt.Errorf("%s.lag: %s", c.Name, err)
+		}
+	}
+
+	if c.Hooks != nil {
+		if err := c.Hooks.Validate(); err != nil {
+			return fmt.Errorf("%s.hooks: %s", c.Name, err)
+		}
+	}
+
+EVENTS:
+	for i := range c.Events {
+		if err := c.Events[i].Validate(); err != nil {
+			return fmt.Errorf("%s.events[%d]: %s", c.Name, i, err)
+		}
+		for k := range c.Trx {
+			if c.Events[i].Trx == c.Trx[k].Name {
+				continue EVENTS
+			}
+		}
+		return fmt.Errorf("%s.events[%d].trx: '%s' not defined in %s.trx", c.Name, i, c.Events[i].Trx, c.Name)
+	}
 
 	return nil
 }
@@ -343,16 +444,25 @@ func (c *Data) Vars(params map[string]string) error {
 
 type ClientGroup struct {
 	Clients       string   `yaml:"clients,omitempty"` // uint
+	ConcurrentDDL bool     `yaml:"concurrent-ddl,omitempty"`
+	Connection    string   `yaml:"connection,omitempty"`
 	Db            string   `yaml:"db,omitempty"`
 	DisableStats  bool     `yaml:"disable-stats,omitempty"`
+	Errors        Errors   `yaml:"errors,omitempty"`
+	IdleIter      string   `yaml:"idle-iter,omitempty"`
+	IdleTrx       string   `yaml:"idle-trx,omitempty"`
 	Iter          string   `yaml:"iter,omitempty"`            // uint
 	IterClients   string   `yaml:"iter-clients,omitempty"`    // uint
 	IterExecGroup string   `yaml:"iter-exec-group,omitempty"` // uint
 	Group         string   `yaml:"group,omitempty"`
+	MySQL         *MySQL   `yaml:"mysql,omitempty"`
 	QPS           string   `yaml:"qps,omitempty"`            // uint
 	QPSClients    string   `yaml:"qps-clients,omitempty"`    // uint
 	QPSExecGroup  string   `yaml:"qps-exec-group,omitempty"` // uint
+	Retries       string   `yaml:"retries,omitempty"`        // uint
 	Runtime       string   `yaml:"runtime,omitempty"`
+	Session       Session  `yaml:"session,omitempty"`
+	Timeout       string   `yaml:"timeout,omitempty"`
 	TPS           string   `yaml:"tps,omitempty"`
 	TPSClients    string   `yaml:"tps-clients,omitempty"`
 	TPSExecGroup  string   `yaml:"tps-exec-group,omitempty"`
@@ -397,9 +507,45 @@ func (c *ClientGroup) Validate(w []Trx) error {
 		return fmt.Errorf("tps-exec-group: '%s' is not an integer: %s", c.TPSExecGroup, err)
 	}
 
+	if err := parseInt(c.Retries); err != nil {
+		return fmt.Errorf("retries: '%s' is not an integer: %s", c.Retries, err)
+	}
+
 	if err := ValidFreq(c.Runtime, "workload.runtime"); err != nil {
 		return err
 	}
+	if err := ValidFreq(c.Timeout, "workload.timeout"); err != nil {
+		return err
+	}
+
+	if c.IdleIter != "" {
+		if _, err := idle.Parse(c.IdleIter); err != nil {
+			return fmt.Errorf("idle-iter: %s", err)
+		}
+	}
+	if c.IdleTrx != "" {
+		if _, err := idle.Parse(c.IdleTrx); err != nil {
+			return fmt.Errorf("idle-trx: %s", err)
+		}
+	}
+
+	if err := c.Errors.Validate(); err != nil {
+		return fmt.Errorf("errors: %s", err)
+	}
+
+	if err := c.Session.Validate(); err != nil {
+		return fmt.Errorf("session: %s", err)
+	}
+
+	if _, err := ParseConnection(c.Connection); err != nil {
+		return fmt.Errorf("connection: %s", err)
+	}
+
+	if c.MySQL != nil {
+		if err := c.MySQL.Validate(); err != nil {
+			return fmt.Errorf("mysql: %s", err)
+		}
+	}
 	return nil
 }
 
@@ -441,19 +587,234 @@ func (c *ClientGroup) Vars(params map[string]string) error {
 	if err != nil {
 		return err
 	}
+	c.Timeout, err = Vars(c.Timeout, params, false)
+	if err != nil {
+		return err
+	}
+	c.Retries, err = Vars(c.Retries, params, true)
+	if err != nil {
+		return err
+	}
+	c.IdleIter, err = Vars(c.IdleIter, params, false)
+	if err != nil {
+		return err
+	}
+	c.IdleTrx, err = Vars(c.IdleTrx, params, false)
+	if err != nil {
+		return err
+	}
 	c.Group, err = Vars(c.Group, params, false)
 	if err != nil {
 		return err
 	}
+	c.Connection, err = Vars(c.Connection, params, false)
+	if err != nil {
+		return err
+	}
 	for i := range c.Trx {
 		c.Trx[i], err = Vars(c.Trx[i], params, false)
 		if err != nil {
 			return err
 		}
 	}
+	if err := c.Errors.Vars(params); err != nil {
+		return err
+	}
+	if err := c.Session.Vars(params); err != nil {
+		return err
+	}
+	if c.MySQL != nil {
+		if err := c.MySQL.Vars(params); err != nil {
+			return fmt.Errorf("in mysql: %s", err)
+		}
+	}
+	return nil
+}
+
+// Connection is a parsed workload[].connection: when clients make a new MySQL
+// connection, in addition to reconnecting on error. The zero value is the default:
+// one connection for the life of the client.
+type Connection struct {
+	Trx  bool          // every trx
+	Iter uint          // every N iterations
+	Time time.Duration // every duration, checked before each trx
+}
+
+// ParseConnection parses workload[].connection:
+//
+//	client    one connection for the client (default)
+//	trx       new connection every trx
+//	iter [N]  new connection every N iterations (default 1)
+//	time D    new connection every duration D, like 5s
+func ParseConnection(s string) (Connection, error) {
+	f := strings.Fields(strings.ToLower(s))
+	if len(f) == 0 {
+		return Connection{}, nil
+	}
+	switch f[0] {
+	case "client", "trx":
+		if len(f) > 1 {
+			return Connection{}, fmt.Errorf("'%s' takes no value: %s", f[0], s)
+		}
+		return Connection{Trx: f[0] == "trx"}, nil
+	case "iter":
+		if len(f) == 1 {
+			return Connection{Iter: 1}, nil
+		}
+		n, err := strconv.ParseUint(f[1], 10, 32)
+		if err != nil || n == 0 || len(f) > 2 {
+			return Connection{}, fmt.Errorf("'iter N' requires one integer N > 0: %s", s)
+		}
+		return Connection{Iter: uint(n)}, nil
+	case "time":
+		if len(f) != 2 {
+			return Connection{}, fmt.Errorf("'time D' requires one duration D: %s", s)
+		}
+		d, err := time.ParseDuration(f[1])
+		if err != nil || d <= 0 {
+			return Connection{}, fmt.Errorf("'time D' requires a duration D > 0, like 5s: %s", s)
+		}
+		return Connection{Time: d}, nil
+	}
+	return Connection{}, fmt.Errorf("invalid value: '%s': valid values: client, trx, iter N, time D", s)
+}
+
+// --------------------------------------------------------------------------
+
+// Session maps MySQL session variables to values that a client sets on every
+// connect and reconnect (after USE db):
+//
+//	session:
+//	  transaction_isolation: READ-COMMITTED
+//	  innodb_lock_wait_timeout: 5
+//
+// It's set in workload[].session.
+type Session map[string]string
+
+var reSessionVar = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+var reSessionNumber = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+func (c Session) Vars(params map[string]string) error {
+	var err error
+	for k, v := range c {
+		c[k], err = Vars(v, params, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c Session) Validate() error {
+	for k := range c {
+		if !reSessionVar.MatchString(k) {
+			return fmt.Errorf("invalid variable name: '%s'", k)
+		}
+	}
+	return nil
+}
+
+// SQL returns one SET SESSION statement for all variables in name order, or
+// an empty string if there are none. Numbers and ON, OFF, TRUE, FALSE, and
+// DEFAULT are not quoted; other values are quoted strings.
+func (c Session) SQL() string {
+	if len(c) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(c))
+	for k := range c {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	set := make([]string, len(names))
+	for i, k := range names {
+		v := c[k]
+		switch strings.ToUpper(v) {
+		case "ON", "OFF", "TRUE", "FALSE", "DEFAULT":
+		default:
+			if !reSessionNumber.MatchString(v) {
+				v = "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+			}
+		}
+		set[i] = k + "=" + v
+	}
+	return "SET SESSION " + strings.Join(set, ", ")
+}
+
+// --------------------------------------------------------------------------
+
+// Errors maps MySQL error codes or classes to error handling actions:
+//
+//	errors:
+//	  1062: continue
+//	  deadlock: rollback, continue
+//
+// Keys are MySQL error codes or classes in finch.MySQLErrorClasses. Values are
+// a CSV list of actions in finch.ErrorActions. It's set in stage.errors and
+// workload[].errors, which overrides the former.
+type Errors map[string]string
+
+func (c Errors) Vars(params map[string]string) error {
+	var err error
+	for k, v := range c {
+		c[k], err = Vars(v, params, false)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func (c Errors) Validate() error {
+	_, err := c.Flags()
+	return err
+}
+
+// Flags returns the error handling flags keyed on MySQL error code. Classes
+// are expanded to their error codes, but an explicit error code takes precedence
+// over a class that includes the same code.
+func (c Errors) Flags() (map[uint16]byte, error) {
+	flags := map[uint16]byte{}
+	codes := map[uint16]bool{} // explicit codes, not classes
+	for k, v := range c {
+		var f byte
+		actions := strings.Split(v, ",")
+		for _, a := range actions {
+			a = strings.ToLower(strings.TrimSpace(a))
+			n, ok := finch.ErrorActions[a]
+			if !ok {
+				return nil, fmt.Errorf("%s: invalid action: '%s'", k, a)
+			}
+			if n == finch.Eabort && len(actions) > 1 {
+				return nil, fmt.Errorf("%s: abort cannot be combined with other actions: %s", k, v)
+			}
+			f |= n
+		}
+
+		key := strings.ToLower(strings.TrimSpace(k))
+		if key == "timeout" && f&^(finch.Ereconnect|finch.Esilent) != 0 {
+			// Statement timeout (finch.ErrTimeout) closes the conn, so the client
+			// always reconnects. Use 3024 to handle only MAX_EXECUTION_TIME.
+			return nil, fmt.Errorf("%s: only abort, reconnect, and silent are valid because the client always reconnects after a statement timeout (use 3024 for other actions on MySQL error 3024): %s", k, v)
+		}
+		if class, ok := finch.MySQLErrorClasses[key]; ok {
+			for _, code := range class {
+				if !codes[code] {
+					flags[code] = f
+				}
+			}
+			continue
+		}
+		code, err := strconv.ParseUint(key, 10, 16)
+		if err != nil || uint16(code) == finch.ErrTimeout {
+			return nil, fmt.Errorf("%s: not a MySQL error code or class", k)
+		}
+		flags[uint16(code)] = f
+		codes[uint16(code)] = true
+	}
+	return flags, nil
+}
+
 // --------------------------------------------------------------------------
 
 type MySQL struct {
@@ -506,6 +867,18 @@ func (c *MySQL) With(def MySQL) {
 	c.TLS.With(def.TLS)
 }
 
+// WithTarget is like With but for a different MySQL instance (target): it
+// inherits def (stage.mysql) except the DSN, which overrides all, and the
+// address (hostname or socket) if c sets one.
+func (c *MySQL) WithTarget(def MySQL) {
+	def.DSN = ""
+	if c.Hostname != "" || c.Socket != "" {
+		def.Hostname = ""
+		def.Socket = ""
+	}
+	c.With(def)
+}
+
 func (c *MySQL) Vars(params map[string]string) error {
 	var err error
 	c.Db, err = Vars(c.Db, params, false)
@@ -516,6 +889,14 @@ func (c *MySQL) Vars(params map[string]string) error {
 	if err != nil {
 		return err
 	}
+	c.Hostname, err = Vars(c.Hostname, params, false)
+	if err != nil {
+		return err
+	}
+	c.Socket, err = Vars(c.Socket, params, false)
+	if err != nil {
+		return err
+	}
 	c.MyCnf, err = Vars(c.MyCnf, params, false)
 	if err != nil {
 		return err
@@ -685,9 +1066,13 @@ func (c TLS) LoadTLS(server string) (*tls.Config, error) {
 // --------------------------------------------------------------------------
 
 type Stats struct {
-	Disable *bool                        `yaml:"disable"`
-	Freq    string                       `yaml:"freq,omitempty"`
-	Report  map[string]map[string]string `yaml:"report,omitempty"`
+	Assert          []string                     `yaml:"assert,omitempty"`
+	Disable         *bool                        `yaml:"disable"`
+	Freq            string                       `yaml:"freq,omitempty"`
+	Report          map[string]map[string]string `yaml:"report,omitempty"`
+	ServerMetrics   *ServerMetrics               `yaml:"server-metrics,omitempty"`
+	Digests         *Digests                     `yaml:"digests,omitempty"`
+	LockDiagnostics *LockDiagnostics             `yaml:"lock-diagnostics,omitempty"`
 }
 
 func (c *Stats) Validate() error {
@@ -712,9 +1097,40 @@ func (c *Stats) Validate() error {
 			c.Report[k] = map[string]string{}
 		}
 	}
+	if c.ServerMetrics != nil {
+		if err := c.ServerMetrics.Validate(); err != nil {
+			return fmt.Errorf("stats.server-metrics: %s", err)
+		}
+	}
+	if c.Digests != nil {
+		if err := c.Digests.Validate(); err != nil {
+			return fmt.Errorf("stats.digests: %s", err)
+		}
+	}
+	if c.LockDiagnostics != nil {
+		if err := c.LockDiagnostics.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, rule := range c.Assert {
+		if _, err := ParseAssert(rule); err != nil {
+			return fmt.Errorf("stats.assert: %s", err)
+		}
+	}
 	return nil
 }
 
+// Throughput returns true if any reporter prints throughput (reporter option
+// "throughput"), which requires clients to count bytes received.
+func (c Stats) Throughput() bool {
+	for _, opts := range c.Report {
+		if finch.Bool(opts["throughput"]) {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Stats) Vars(params map[string]string) error {
 	var err error
 	c.Freq, err = Vars(c.Freq, params, false)
@@ -729,5 +1145,339 @@ func (c *Stats) Vars(params map[string]string) error {
 			}
 		}
 	}
+	if c.ServerMetrics != nil {
+		if err := c.ServerMetrics.Vars(params); err != nil {
+			return fmt.Errorf("in server-metrics: %s", err)
+		}
+	}
+	if c.LockDiagnostics != nil {
+		c.LockDiagnostics.Freq, err = Vars(c.LockDiagnostics.Freq, params, false)
+		if err != nil {
+			return fmt.Errorf("in lock-diagnostics: %s", err)
+		}
+	}
+	for i := range c.Assert {
+		// Not numbers=true: human numbers like 1m would be 1000000, not a duration;
+		// ParseAssert parses durations and human numbers
+		c.Assert[i], err = Vars(c.Assert[i], params, false)
+		if err != nil {
+			return fmt.Errorf("in assert: %s", err)
+		}
+	}
+	return nil
+}
+
+// Assert is a parsed stats.assert rule: "METRIC OP VALUE [for trx NAME]",
+// like "p99 < 5ms for trx read-only". The metric is validated and evaluated
+// by the stats package because metrics are stats columns, like r_p99.
+type Assert struct {
+	Rule   string
+	Metric string  // lowercase: qps, tps, errors, timeouts, min, max, pN; optional event prefix like r_
+	Op     string  // <, <=, >, >=, ==, !=
+	Value  float64 // microseconds if a time duration, like 5ms
+	Trx    string  // trx name, or "" for all trx
+}
+
+var assertOps = map[string]bool{"<": true, "<=": true, ">": true, ">=": true, "==": true, "!=": true}
+
+var reAssertMetric = regexp.MustCompile(`^[a-z_]*[a-z][0-9.]*$`)
+
+// ParseAssert parses a stats.assert rule.
+func ParseAssert(rule string) (Assert, error) {
+	f := strings.Fields(rule)
+	if len(f) != 3 && !(len(f) == 6 && f[3] == "for" && f[4] == "trx") {
+		return Assert{}, fmt.Errorf("invalid rule: %s: expected METRIC OP VALUE [for trx NAME]", rule)
+	}
+	a := Assert{
+		Rule:   rule,
+		Metric: strings.ToLower(f[0]),
+		Op:     f[1],
+	}
+	if !reAssertMetric.MatchString(a.Metric) {
+		return a, fmt.Errorf("invalid metric in rule: %s: %s", rule, f[0])
+	}
+	if !assertOps[a.Op] {
+		return a, fmt.Errorf("invalid operator in rule: %s: %s: valid operators: < <= > >= == !=", rule, a.Op)
+	}
+	if d, err := time.ParseDuration(f[2]); err == nil && strings.IndexFunc(f[2], unicode.IsLetter) >= 0 {
+		a.Value = float64(d.Microseconds())
+	} else if v, err := strconv.ParseFloat(f[2], 64); err == nil {
+		a.Value = v
+	} else if n, err := human.ParseBytes(f[2]); err == nil && f[2][0] >= '0' && f[2][0] <= '9' {
+		a.Value = float64(n) // human number like 10k or 10,000
+	} else {
+		return a, fmt.Errorf("invalid value in rule: %s: %s: must be a number or time duration", rule, f[2])
+	}
+	if len(f) == 6 {
+		a.Trx = f[5]
+	}
+	return a, nil
+}
+
+// Digests configures the Performance Schema statement digest report printed
+// at the end of each stage: stats.digests.
+type Digests struct {
+	Truncate *bool `yaml:"truncate,omitempty"` // default true
+}
+
+func (c *Digests) Validate() error {
+	if c.Truncate == nil {
+		b := true
+		c.Truncate = &b
+	}
+	return nil
+}
+
+// LockDiagnostics configures capturing InnoDB lock diagnostics on deadlock and
+// lock wait timeout errors: stats.lock-diagnostics.
+type LockDiagnostics struct {
+	Freq string `yaml:"freq,omitempty"` // max capture frequency
+}
+
+func (c *LockDiagnostics) Validate() error {
+	if c.Freq == "" {
+		c.Freq = "1s"
+	}
+	return ValidFreq(c.Freq, "stats.lock-diagnostics.freq")
+}
+
+// ServerMetrics configures MySQL metrics sampled each stats interval:
+// stats.server-metrics. If both lists are empty, the defaults are used.
+type ServerMetrics struct {
+	Status        []string `yaml:"status,omitempty"`         // SHOW GLOBAL STATUS
+	InnoDBMetrics []string `yaml:"innodb-metrics,omitempty"` // information_schema.INNODB_METRICS
+}
+
+func (c *ServerMetrics) Validate() error {
+	if len(c.Status) == 0 && len(c.InnoDBMetrics) == 0 {
+		c.Status = []string{"Innodb_rows_read", "Innodb_buffer_pool_reads", "Threads_running"}
+		c.InnoDBMetrics = []string{"trx_rseg_history_len"}
+	}
+	for _, name := range append(c.Status, c.InnoDBMetrics...) {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("empty metric name")
+		}
+	}
 	return nil
 }
+
+func (c *ServerMetrics) Vars(params map[string]string) error {
+	var err error
+	for i := range c.Status {
+		c.Status[i], err = Vars(c.Status[i], params, false)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range c.InnoDBMetrics {
+		c.InnoDBMetrics[i], err = Vars(c.InnoDBMetrics[i], params, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+
+// Lag configures the replication lag probe: a heartbeat written to stage.mysql
+// (the source) and read from Replica.
+type Lag struct {
+	Freq    string `yaml:"freq,omitempty"`
+	Replica MySQL  `yaml:"replica"`
+	Table   string `yaml:"table,omitempty"`
+}
+
+func (c *Lag) Validate() error {
+	if c.Replica.DSN == "" && c.Replica.Hostname == "" && c.Replica.Socket == "" {
+		return fmt.Errorf("replica.hostname, replica.socket, or replica.dsn must be set")
+	}
+	if c.Freq == "" {
+		c.Freq = "100ms"
+	} else if err := ValidFreq(c.Freq, "lag.freq"); err != nil {
+		return err
+	}
+	if c.Table == "" {
+		c.Table = "finch.heartbeat"
+	}
+	if strings.Count(c.Table, ".") != 1 {
+		return fmt.Errorf("table %s must be database-qualified: db.tbl", c.Table)
+	}
+	return nil
+}
+
+func (c *Lag) Vars(params map[string]string) error {
+	var err error
+	c.Freq, err = Vars(c.Freq, params, false)
+	if err != nil {
+		return err
+	}
+	c.Table, err = Vars(c.Table, params, false)
+	if err != nil {
+		return err
+	}
+	if err := c.Replica.Vars(params); err != nil {
+		return fmt.Errorf("in replica: %s", err)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+
+// Hooks are SQL statements or local commands run on the server at points in
+// the stage lifecycle (package hook).
+type Hooks struct {
+	BeforeStage []Hook `yaml:"before-stage,omitempty"` // before instances boot
+	BeforeRun   []Hook `yaml:"before-run,omitempty"`   // after boot, before clients run
+	OnInterval  []Hook `yaml:"on-interval,omitempty"`  // end of each stats interval
+	AfterStage  []Hook `yaml:"after-stage,omitempty"`  // after all instances finish
+}
+
+// Hook is one SQL statement or local command (run by sh -c). Only one can be set.
+type Hook struct {
+	SQL     string `yaml:"sql,omitempty"`
+	Exec    string `yaml:"exec,omitempty"`
+	Abort   bool   `yaml:"abort,omitempty"`   // abort stage on error
+	Timeout string `yaml:"timeout,omitempty"` // duration
+}
+
+// All returns all hooks keyed on lifecycle point, like "before-stage".
+func (c *Hooks) All() map[string][]Hook {
+	return map[string][]Hook{
+		"before-stage": c.BeforeStage,
+		"before-run":   c.BeforeRun,
+		"on-interval":  c.OnInterval,
+		"after-stage":  c.AfterStage,
+	}
+}
+
+func (c *Hooks) Validate() error {
+	for point, hooks := range c.All() {
+		for i := range hooks {
+			if err := hooks[i].Validate(); err != nil {
+				return fmt.Errorf("%s[%d]: %s", point, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Hooks) Vars(params map[string]string) error {
+	for point, hooks := range c.All() {
+		for i := range hooks { // hooks shares the backing array, so this sets c
+			if err := hooks[i].Vars(params); err != nil {
+				return fmt.Errorf("in %s[%d]: %s", point, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Hook) Validate() error {
+	if c.SQL == "" && c.Exec == "" {
+		return fmt.Errorf("sql or exec must be set")
+	}
+	if c.SQL != "" && c.Exec != "" {
+		return fmt.Errorf("sql and exec are mutually exclusive; set only one")
+	}
+	return ValidFreq(c.Timeout, "hooks.timeout")
+}
+
+func (c *Hook) Vars(params map[string]string) error {
+	var err error
+	c.SQL, err = Vars(c.SQL, params, false)
+	if err != nil {
+		return err
+	}
+	c.Exec, err = Vars(c.Exec, params, false)
+	if err != nil {
+		return err
+	}
+	c.Timeout, err = Vars(c.Timeout, params, false)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+
+// Event runs a trx once at a time offset or repeatedly at an interval from the
+// start of the stage, on a dedicated client, while the workload runs. Only one
+// of At or Every can be set.
+type Event struct {
+	At    string `yaml:"at,omitempty"`    // duration >= 0
+	Every string `yaml:"every,omitempty"` // duration > 0
+	Trx   string `yaml:"trx"`             // trx name
+}
+
+func (c *Event) Validate() error {
+	if c.Trx == "" {
+		return fmt.Errorf("trx not set")
+	}
+	if (c.At == "") == (c.Every == "") {
+		return fmt.Errorf("at or every must be set, but not both")
+	}
+	if c.At != "" {
+		d, err := time.ParseDuration(c.At)
+		if err != nil {
+			return fmt.Errorf("invalid at: %s: %s", c.At, err)
+		}
+		if d < 0 {
+			return fmt.Errorf("invalid at: %s: must be >= 0", c.At)
+		}
+		return nil
+	}
+	return ValidFreq(c.Every, "events.every")
+}
+
+func (c *Event) Vars(params map[string]string) error {
+	var err error
+	c.At, err = Vars(c.At, params, false)
+	if err != nil {
+		return err
+	}
+	c.Every, err = Vars(c.Every, params, false)
+	if err != nil {
+		return err
+	}
+	c.Trx, err = Vars(c.Trx, params, false)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// EventTrx returns the names of trx used only by events, not by any client
+// group in the workload. These trx are not auto-assigned to client groups.
+func (c *Stage) EventTrx() map[string]bool {
+	if len(c.Events) == 0 {
+		return nil
+	}
+	m := map[string]bool{}
+	for _, e := range c.Events {
+		m[e.Trx] = true
+	}
+	for _, cg := range c.Workload {
+		for _, trxName := range cg.Trx {
+			delete(m, trxName)
+		}
+	}
+	return m
+}
+
+// RemoveEvents removes events and trx used only by events (EventTrx). It's
+// called on remote compute instances because events run only on the server.
+func (c *Stage) RemoveEvents() {
+	eventTrx := c.EventTrx()
+	if len(eventTrx) > 0 {
+		trx := make([]Trx, 0, len(c.Trx))
+		for _, t := range c.Trx {
+			if !eventTrx[t.Name] {
+				trx = append(trx, t)
+			}
+		}
+		c.Trx = trx
+	}
+	c.Events = nil
+}
@@ -4,6 +4,7 @@
 package dbconn
 
 import (
+	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"io/fs"
@@ -12,6 +13,7 @@ import (
 	"os/exec"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/go-sql-driver/mysql"
 
@@ -29,19 +31,65 @@ var rdsAddr = regexp.MustCompile(`rds\.amazonaws\.com(:\d+)?$`)
 // strip the port suffix before passing the hostname to LoadTLS.
 var portSuffix = regexp.MustCompile(`:\d+$`)
 
-var f = &factory{}
+var f = &factory{tlsName: "benchmark"}
+
+// tlsNames maps each unique TLS config registered by MakeWith (see tlsKey) to
+// its name. The MySQL driver TLS registry is global and MakeWith is called
+// many times (per client group, per stage, and more), so each unique TLS config
+// is registered only once, else the registry grows for the life of the process.
+var (
+	tlsMux   = &sync.Mutex{}
+	tlsNames = map[string]string{}
+)
 
 type factory struct {
-	cfg config.MySQL
-	dsn string
+	cfg     config.MySQL
+	dsn     string
+	tlsName string
 }
 
+// Option is an optional connection setting for Make and MakeWith.
+type Option byte
+
+const (
+	// MultiStatements enables the MySQL multi-statement protocol
+	// (multiStatements=true). workload.Allocator.Clients sets it only for
+	// client groups with batched statements (trx modifier "batch").
+	MultiStatements Option = iota
+)
+
 func SetConfig(cfg config.MySQL) {
 	f.cfg = cfg
 	f.dsn = ""
 }
 
-func Make() (*sql.DB, string, error) {
+// Make makes a new *sql.DB using the config from SetConfig: stage.mysql.
+func Make(opts ...Option) (*sql.DB, string, error) {
+	return f.make(opts)
+}
+
+// MakeWith makes a new *sql.DB using the given config instead of the config
+// from SetConfig. It's used for client groups with their own MySQL target:
+// workload[].mysql.
+func MakeWith(cfg config.MySQL, opts ...Option) (*sql.DB, string, error) {
+	g := &factory{
+		cfg: cfg, // tlsName set by registerTLS
+	}
+	return g.make(opts)
+}
+
+// Addr returns the network address (hostname:port or socket) in the DSN,
+// which identifies the MySQL target in stats. It returns the DSN if it cannot
+// be parsed.
+func Addr(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return dsn
+	}
+	return cfg.Addr
+}
+
+func (f *factory) make(opts []Option) (*sql.DB, string, error) {
 	// Parse MySQL params and set DSN on first call. There's only 1 DSN for
 	// all clients, so this only needs to be done once.
 	if f.dsn == "" {
@@ -49,15 +97,28 @@ func Make() (*sql.DB, string, error) {
 			return nil, "", err
 		}
 	}
-	finch.Debug("dsn: %s", RedactedDSN(f.dsn))
+	dsn := f.dsn
+	for _, opt := range opts {
+		switch opt {
+		case MultiStatements:
+			// Set on the final DSN because --dsn or mysql.dsn overrides all
+			dsnCfg, err := mysql.ParseDSN(dsn)
+			if err != nil {
+				return nil, "", err
+			}
+			dsnCfg.MultiStatements = true
+			dsn = dsnCfg.FormatDSN()
+		}
+	}
+	finch.Debug("dsn: %s", RedactedDSN(dsn))
 
 	// Make new sql.DB (conn pool) for each client group; see the call to
 	// this func in workload/workload.go.
-	db, err := sql.Open("mysql", f.dsn)
+	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, "", err
 	}
-	return db, RedactedDSN(f.dsn), nil
+	return db, RedactedDSN(dsn), nil
 }
 
 func (f *factory) setDSN() error {
@@ -120,8 +181,8 @@ func (f *factory) setDSN() error {
 		return err
 	}
 	if tlsConfig != nil {
-		mysql.RegisterTLSConfig("benchmark", tlsConfig)
-		params = append(params, "tls=benchmark")
+		f.registerTLS(tlsConfig)
+		params = append(params, "tls="+f.tlsName)
 		finch.Debug("TLS enabled")
 	}
 
@@ -168,6 +229,35 @@ func (f *factory) setDSN() error {
 	return nil
 }
 
+// registerTLS registers the TLS config with the MySQL driver. The factory from
+// SetConfig always uses the same name, so it replaces the previous stage TLS
+// config. MakeWith factories use the name of an identical TLS config already
+// registered, if any.
+func (f *factory) registerTLS(tlsConfig *tls.Config) {
+	if f.tlsName != "" {
+		mysql.RegisterTLSConfig(f.tlsName, tlsConfig)
+		return
+	}
+	key := tlsKey(f.cfg)
+	tlsMux.Lock()
+	defer tlsMux.Unlock()
+	if name, ok := tlsNames[key]; ok {
+		f.tlsName = name
+		return
+	}
+	f.tlsName = fmt.Sprintf("benchmark%d", len(tlsNames)+1)
+	mysql.RegisterTLSConfig(f.tlsName, tlsConfig)
+	tlsNames[key] = f.tlsName
+}
+
+// tlsKey returns the values that determine the TLS config loaded by setDSN:
+// the TLS files and options, and the hostname for server verification.
+func tlsKey(cfg config.MySQL) string {
+	return fmt.Sprintf("%s|%s|%s|%t|%t|%s|%s", cfg.TLS.CA, cfg.TLS.Cert, cfg.TLS.Key,
+		config.True(cfg.TLS.SkipVerify), config.True(cfg.TLS.Disable), cfg.TLS.MySQLMode,
+		portSuffix.ReplaceAllString(cfg.Hostname, ""))
+}
+
 const (
 	default_mysql_socket  = "/tmp/mysql.sock"
 	default_distro_socket = "/var/lib/mysql/mysql.sock"
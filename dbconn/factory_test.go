@@ -3,6 +3,8 @@
 package dbconn_test
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -90,3 +92,92 @@ func TestMake_Config(t *testing.T) {
 		t.Errorf("SELECT @@version: got %s, expected 8.0.34", got)
 	}
 }
+
+func TestAddr(t *testing.T) {
+	// No MySQL required: MakeWith only opens a lazy *sql.DB
+	dbconn.SetConfig(config.MySQL{Hostname: "primary:3306"})
+	db, dsn, err := dbconn.Make()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if got := dbconn.Addr(dsn); got != "primary:3306" {
+		t.Errorf("stage target: got %s, expected primary:3306", got)
+	}
+
+	db, dsn, err = dbconn.MakeWith(config.MySQL{Socket: "/tmp/replica.sock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if got := dbconn.Addr(dsn); got != "/tmp/replica.sock" {
+		t.Errorf("client group target: got %s, expected /tmp/replica.sock", got)
+	}
+}
+
+func TestMake_MultiStatements(t *testing.T) {
+	// No MySQL required: Make only opens a lazy *sql.DB
+	dbconn.SetConfig(config.MySQL{Hostname: "primary:3306"})
+	db, dsn, err := dbconn.Make()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if strings.Contains(dsn, "multiStatements") {
+		t.Errorf("multiStatements set without option: %s", dsn)
+	}
+
+	db, dsn, err = dbconn.Make(dbconn.MultiStatements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if !strings.Contains(dsn, "multiStatements=true") {
+		t.Errorf("multiStatements not set with option: %s", dsn)
+	}
+
+	// Option applies only to that call
+	db, dsn, err = dbconn.Make()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if strings.Contains(dsn, "multiStatements") {
+		t.Errorf("multiStatements set after call with option: %s", dsn)
+	}
+}
+
+func TestMakeWith_TLSRegisteredOnce(t *testing.T) {
+	// No MySQL required: MakeWith only opens a lazy *sql.DB. The CA file
+	// doesn't have to be a valid cert to be loaded.
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(ca, []byte("ca"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tlsParam := func(hostname string) string {
+		db, dsn, err := dbconn.MakeWith(config.MySQL{Hostname: hostname, TLS: config.TLS{CA: ca}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg.TLSConfig
+	}
+
+	// Same TLS config: same registered name
+	name1 := tlsParam("replica1")
+	if name1 == "" {
+		t.Fatal("TLS not enabled")
+	}
+	if name2 := tlsParam("replica1"); name2 != name1 {
+		t.Errorf("same TLS config registered twice: %s and %s", name1, name2)
+	}
+
+	// Different server name: different TLS config
+	if name3 := tlsParam("replica2"); name3 == name1 {
+		t.Errorf("different TLS config not registered: %s", name3)
+	}
+}
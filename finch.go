@@ -190,18 +190,50 @@ const (
 	Econtinue              // don't reconnect, continue next iter
 	Esilent                // don't repot error or reconnect
 	Erollback              // execute ROLLBACK if in trx
+	Eretry                 // retry the trx, up to workload[].retries times
 )
 
+// ErrorActions maps stage.errors actions to error handling flags.
+var ErrorActions = map[string]byte{
+	"abort":     Eabort,
+	"reconnect": Ereconnect,
+	"continue":  Econtinue,
+	"silent":    Esilent,
+	"rollback":  Erollback,
+	"retry":     Eretry,
+}
+
+// MySQLErrorClasses maps stage.errors class names to MySQL error codes.
+var MySQLErrorClasses = map[string][]uint16{
+	"deadlock":          {1213},
+	"duplicate-key":     {1062},
+	"lock-wait-timeout": {1205},
+	"query-killed":      {1317},
+	"read-only":         {1290, 1836},
+	"timeout":           {ErrTimeout, 3024},
+}
+
+// ErrTimeout is the error code recorded when a statement timeout (trx modifier
+// timeout or workload[].timeout) elapses. It's not a MySQL error code, but it's
+// handled and counted like one. MySQL error 3024 is the server-side equivalent
+// when MAX_EXECUTION_TIME elapses.
+const ErrTimeout uint16 = 65535
+
+// MySQLErrorHandling is the default error handling. No default stops the client:
+// only an explicit abort in stage.errors or workload[].errors does that.
 var MySQLErrorHandling = map[uint16]byte{
-	1046: Eabort,                // no database selected
-	1062: Eabort,                // duplicate key
-	1064: Eabort,                // You have an error in your SQL syntax
-	1146: Eabort,                // table doesn't exist
+	1046: Ereconnect,            // no database selected
+	1062: Ereconnect,            // duplicate key
+	1064: Ereconnect,            // You have an error in your SQL syntax
+	1146: Ereconnect,            // table doesn't exist
 	1205: Erollback | Econtinue, // lock wait timeout; no automatic rollback (innodb_rollback_on_timeout=OFF by default)
 	1213: Econtinue,             // deadlock; automatic rollback
 	1290: Erollback | Econtinue, // read-only (server is running with the --read-only option so it cannot execute this statement)
 	1317: Econtinue,             // query killed (Query execution was interrupted)
 	1836: Erollback | Econtinue, // read-only (Running in read-only mode)
+	3024: Econtinue,             // MAX_EXECUTION_TIME exceeded (maximum statement execution time exceeded)
+
+	ErrTimeout: Ereconnect, // statement timeout; driver closes conn when ctx is cancelled
 }
 
 var ModifyDB func(*sql.DB, RunLevel)
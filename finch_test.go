@@ -80,3 +80,13 @@ func TestRunLevelGreaterThan(t *testing.T) {
 		t.Errorf("Client changed but got false for ITER")
 	}
 }
+
+func TestMySQLErrorHandling(t *testing.T) {
+	// Eabort is zero, so a default of Eabort would stop clients on errors
+	// that have always reconnected. Only an explicit stage.errors abort stops.
+	for code, flags := range finch.MySQLErrorHandling {
+		if flags == finch.Eabort {
+			t.Errorf("error %d: default is abort, expected reconnect or continue", code)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2024 Block, Inc.
+
+// Package hook runs stage hooks (config.stage.hooks): SQL statements or local
+// commands at points in the stage lifecycle. Hooks run only on the server.
+package hook
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/square/finch"
+	"github.com/square/finch/config"
+	"github.com/square/finch/dbconn"
+	"github.com/square/finch/stats"
+)
+
+// Lifecycle points, in order, which are also the config.stage.hooks keys.
+const (
+	BEFORE_STAGE = "before-stage" // before instances boot
+	BEFORE_RUN   = "before-run"   // after boot, before clients run
+	ON_INTERVAL  = "on-interval"  // end of each stats interval
+	AFTER_STAGE  = "after-stage"  // after all instances finish
+)
+
+// Runner runs the hooks for one stage. SQL hooks run on stage.mysql using one
+// connection pool made on the first SQL hook. Commands run with sh -c and two
+// extra environment variables: FINCH_STAGE (stage name) and FINCH_HOOK (lifecycle
+// point).
+//
+// Runner is a stats.Sampler: each interval, Sample runs the on-interval hooks
+// in the background so slow hooks don't delay stats. If on-interval hooks are
+// still running from the previous interval, that interval is skipped.
+type Runner struct {
+	stage string
+	hooks map[string][]config.Hook
+	mysql config.MySQL
+	// --
+	*sync.Mutex
+	db *sql.DB // made on first SQL hook
+
+	// on-interval (see Start)
+	ctx     context.Context
+	abort   context.CancelFunc
+	running int32 // atomic
+	done    *sync.WaitGroup
+	err     error // from hook that aborted the stage
+}
+
+var _ stats.Sampler = &Runner{}
+
+func NewRunner(stageName string, cfg config.Hooks, mysql config.MySQL) *Runner {
+	return &Runner{
+		stage: stageName,
+		hooks: cfg.All(),
+		mysql: mysql,
+		Mutex: &sync.Mutex{},
+		done:  &sync.WaitGroup{},
+	}
+}
+
+// Run runs the hooks at the lifecycle point in order. If a hook fails, the error
+// is logged and the next hook runs, unless hook.abort is true: then Run returns
+// the error without running the remaining hooks.
+func (r *Runner) Run(ctx context.Context, point string) error {
+	for i, h := range r.hooks[point] {
+		log.Printf("[%s] Hook %s[%d]: %s", r.stage, point, i, hookString(h))
+		t0 := time.Now()
+		err := r.run(ctx, point, h)
+		finch.Debug("hook %s[%d]: %s: %v", point, i, time.Since(t0), err)
+		if err == nil {
+			continue
+		}
+		if h.Abort {
+			return fmt.Errorf("hook %s[%d] failed, aborting stage: %s", point, i, err)
+		}
+		log.Printf("[%s] Hook %s[%d] failed, ignoring because abort=false: %s", r.stage, point, i, err)
+	}
+	return nil
+}
+
+// Start enables on-interval hooks. If one aborts, the abort func is called to
+// stop the stage, and Stop returns the error.
+func (r *Runner) Start(ctx context.Context, abort context.CancelFunc) {
+	r.ctx = ctx
+	r.abort = abort
+}
+
+// Sample runs the on-interval hooks in the background. It returns no metrics.
+func (r *Runner) Sample() []stats.Metric {
+	if r.ctx == nil || len(r.hooks[ON_INTERVAL]) == 0 || r.ctx.Err() != nil {
+		return nil
+	}
+	if !atomic.CompareAndSwapInt32(&r.running, 0, 1) {
+		log.Printf("[%s] Hook %s still running from previous interval, skipping this interval", r.stage, ON_INTERVAL)
+		return nil
+	}
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+		defer atomic.StoreInt32(&r.running, 0)
+		if err := r.Run(r.ctx, ON_INTERVAL); err != nil {
+			r.Lock()
+			if r.err == nil {
+				r.err = err
+			}
+			r.Unlock()
+			log.Printf("[%s] %s", r.stage, err)
+			r.abort()
+		}
+	}()
+	return nil
+}
+
+// Stop waits for on-interval hooks to finish and returns the error from the
+// hook that aborted the stage, if any.
+func (r *Runner) Stop() error {
+	r.done.Wait()
+	r.Lock()
+	defer r.Unlock()
+	return r.err
+}
+
+// Close closes the MySQL connection pool, if any.
+func (r *Runner) Close() {
+	r.Lock()
+	defer r.Unlock()
+	if r.db != nil {
+		r.db.Close()
+		r.db = nil
+	}
+}
+
+func (r *Runner) run(ctx context.Context, point string, h config.Hook) error {
+	if h.Timeout != "" {
+		d, _ := time.ParseDuration(h.Timeout) // already validated
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	if h.SQL != "" {
+		db, err := r.conn()
+		if err != nil {
+			return err
+		}
+		_, err = db.ExecContext(ctx, h.SQL)
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Exec)
+	cmd.Env = append(os.Environ(), "FINCH_STAGE="+r.stage, "FINCH_HOOK="+point)
+	cmd.WaitDelay = time.Second // don't wait for children of sh holding output open
+	out, err := cmd.CombinedOutput()
+	if s := strings.TrimSpace(string(out)); s != "" {
+		fmt.Println(s)
+	}
+	return err
+}
+
+func (r *Runner) conn() (*sql.DB, error) {
+	r.Lock()
+	defer r.Unlock()
+	if r.db != nil {
+		return r.db, nil
+	}
+	db, _, err := dbconn.MakeWith(r.mysql)
+	if err != nil {
+		return nil, err
+	}
+	r.db = db
+	return db, nil
+}
+
+// hookString returns the SQL or command for logging.
+func hookString(h config.Hook) string {
+	if h.SQL != "" {
+		return h.SQL
+	}
+	return "sh -c '" + h.Exec + "'"
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Block, Inc.
+
+package hook_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/square/finch/config"
+	"github.com/square/finch/hook"
+)
+
+func TestRunner_Exec(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	cfg := config.Hooks{
+		BeforeStage: []config.Hook{
+			{Exec: "echo $FINCH_STAGE $FINCH_HOOK >> " + out},
+			{Exec: "exit 1"}, // abort=false: logged, next hook runs
+			{Exec: "echo 2 >> " + out},
+		},
+		BeforeRun: []config.Hook{
+			{Exec: "exit 1", Abort: true},
+			{Exec: "echo not run >> " + out},
+		},
+		AfterStage: []config.Hook{
+			{Exec: "sleep 2", Timeout: "100ms", Abort: true},
+		},
+	}
+	r := hook.NewRunner("s1", cfg, config.MySQL{})
+	defer r.Close()
+
+	if err := r.Run(context.Background(), hook.BEFORE_STAGE); err != nil {
+		t.Errorf("got error %v, expected nil because failed hook has abort=false", err)
+	}
+	if err := r.Run(context.Background(), hook.BEFORE_RUN); err == nil {
+		t.Error("no error from failed hook with abort=true")
+	}
+	if err := r.Run(context.Background(), hook.AFTER_STAGE); err == nil {
+		t.Error("no error from hook that timed out")
+	}
+
+	bytes, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "s1 before-stage\n2\n"
+	if string(bytes) != expect {
+		t.Errorf("got output %q, expected %q", string(bytes), expect)
+	}
+}
+
+func TestRunner_OnIntervalAbort(t *testing.T) {
+	cfg := config.Hooks{
+		OnInterval: []config.Hook{{Exec: "exit 1", Abort: true}},
+	}
+	r := hook.NewRunner("s1", cfg, config.MySQL{})
+	defer r.Close()
+
+	// Not started: Sample does nothing
+	r.Sample()
+	if err := r.Stop(); err != nil {
+		t.Errorf("got error %v before Start, expected nil", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx, cancel)
+	if m := r.Sample(); len(m) != 0 {
+		t.Errorf("got metrics %v, expected none", m)
+	}
+	if err := r.Stop(); err == nil {
+		t.Error("no error from on-interval hook with abort=true")
+	}
+	if ctx.Err() == nil {
+		t.Error("stage context not canceled")
+	}
+}
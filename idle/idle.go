@@ -0,0 +1,155 @@
+// Copyright 2024 Block, Inc.
+
+// Package idle provides think time: how long a client sleeps, either a fixed
+// time or a random time from a distribution.
+package idle
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Time is a think time. Duration is called for each sleep, so random
+// distributions return a new value on each call. It's safe for concurrent
+// use by multiple clients.
+type Time interface {
+	Duration() time.Duration
+	String() string
+}
+
+// Parse parses a think time:
+//
+//	5ms                   fixed
+//	uniform 1ms 10ms      uniform between min and max
+//	exponential 5ms       exponential with mean (Poisson arrivals)
+//	normal 5ms 1ms        normal with mean and standard deviation
+//
+// It's used for the trx file idle modifier, workload[].idle-trx, and
+// workload[].idle-iter.
+func Parse(s string) (Time, error) {
+	f := strings.Fields(s)
+	if len(f) == 0 {
+		return nil, fmt.Errorf("no idle time")
+	}
+	if len(f) == 1 {
+		d, err := duration(f[0])
+		if err != nil {
+			return nil, err
+		}
+		return Fixed(d), nil
+	}
+	switch f[0] {
+	case "uniform":
+		if len(f) != 3 {
+			return nil, fmt.Errorf("invalid uniform idle time: %s: expected: uniform MIN MAX", s)
+		}
+		min, err := duration(f[1])
+		if err != nil {
+			return nil, err
+		}
+		max, err := duration(f[2])
+		if err != nil {
+			return nil, err
+		}
+		if min > max {
+			return nil, fmt.Errorf("invalid uniform idle time: %s: min > max", s)
+		}
+		return Uniform{Min: min, Max: max}, nil
+	case "exponential":
+		if len(f) != 2 {
+			return nil, fmt.Errorf("invalid exponential idle time: %s: expected: exponential MEAN", s)
+		}
+		mean, err := duration(f[1])
+		if err != nil {
+			return nil, err
+		}
+		return Exponential{Mean: mean}, nil
+	case "normal":
+		if len(f) != 3 {
+			return nil, fmt.Errorf("invalid normal idle time: %s: expected: normal MEAN STDDEV", s)
+		}
+		mean, err := duration(f[1])
+		if err != nil {
+			return nil, err
+		}
+		stddev, err := duration(f[2])
+		if err != nil {
+			return nil, err
+		}
+		return Normal{Mean: mean, StdDev: stddev}, nil
+	}
+	return nil, fmt.Errorf("invalid idle time: %s: unknown distribution %s; valid: uniform, exponential, normal", s, f[0])
+}
+
+func duration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid idle time: %s: %s", s, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid idle time: %s: must be >= 0", s)
+	}
+	return d, nil
+}
+
+// --------------------------------------------------------------------------
+
+// Fixed is a constant think time.
+type Fixed time.Duration
+
+func (t Fixed) Duration() time.Duration {
+	return time.Duration(t)
+}
+
+func (t Fixed) String() string {
+	return time.Duration(t).String()
+}
+
+// Uniform is a random think time between Min and Max, inclusive.
+type Uniform struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func (t Uniform) Duration() time.Duration {
+	return t.Min + time.Duration(rand.Int63n(int64(t.Max-t.Min)+1))
+}
+
+func (t Uniform) String() string {
+	return fmt.Sprintf("uniform %s %s", t.Min, t.Max)
+}
+
+// Exponential is a random think time with an exponential distribution, which
+// models Poisson arrivals: mostly short waits with an occasional long one.
+type Exponential struct {
+	Mean time.Duration
+}
+
+func (t Exponential) Duration() time.Duration {
+	return time.Duration(rand.ExpFloat64() * float64(t.Mean))
+}
+
+func (t Exponential) String() string {
+	return fmt.Sprintf("exponential %s", t.Mean)
+}
+
+// Normal is a random think time with a normal distribution. Negative values
+// are returned as zero.
+type Normal struct {
+	Mean   time.Duration
+	StdDev time.Duration
+}
+
+func (t Normal) Duration() time.Duration {
+	d := time.Duration(rand.NormFloat64()*float64(t.StdDev) + float64(t.Mean))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (t Normal) String() string {
+	return fmt.Sprintf("normal %s %s", t.Mean, t.StdDev)
+}
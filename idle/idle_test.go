@@ -0,0 +1,77 @@
+// Copyright 2024 Block, Inc.
+
+package idle_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/square/finch/idle"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		s      string
+		expect idle.Time
+	}{
+		{"5ms", idle.Fixed(5 * time.Millisecond)},
+		{"uniform 1ms 10ms", idle.Uniform{Min: time.Millisecond, Max: 10 * time.Millisecond}},
+		{"exponential 5ms", idle.Exponential{Mean: 5 * time.Millisecond}},
+		{"normal 5ms 1ms", idle.Normal{Mean: 5 * time.Millisecond, StdDev: time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := idle.Parse(tt.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := deep.Equal(got, tt.expect); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+
+	invalid := []string{
+		"",
+		"5",
+		"-1ms",
+		"uniform 10ms 1ms", // min > max
+		"uniform 1ms",
+		"exponential",
+		"poisson 5ms",
+	}
+	for _, s := range invalid {
+		if _, err := idle.Parse(s); err == nil {
+			t.Errorf("no error for '%s', expected parse error", s)
+		}
+	}
+}
+
+func TestDistributions(t *testing.T) {
+	u := idle.Uniform{Min: time.Millisecond, Max: 2 * time.Millisecond}
+	for i := 0; i < 1000; i++ {
+		if d := u.Duration(); d < u.Min || d > u.Max {
+			t.Fatalf("uniform %s out of range: %s", u, d)
+		}
+	}
+
+	// Mean of many samples should be close to the configured mean
+	e := idle.Exponential{Mean: time.Millisecond}
+	var sum time.Duration
+	n := 10000
+	for i := 0; i < n; i++ {
+		sum += e.Duration()
+	}
+	if mean := sum / time.Duration(n); mean < 900*time.Microsecond || mean > 1100*time.Microsecond {
+		t.Errorf("exponential mean %s, expected ~1ms", mean)
+	}
+
+	nd := idle.Normal{Mean: 0, StdDev: time.Millisecond}
+	for i := 0; i < 1000; i++ {
+		if d := nd.Duration(); d < 0 {
+			t.Fatalf("normal returned negative duration: %s", d)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2024 Block, Inc.
+
+// Package lag provides a replication lag probe: a heartbeat writer on the source
+// (stage.mysql) and a reader on a replica (stage.lag.replica).
+package lag
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/square/finch"
+	"github.com/square/finch/config"
+	"github.com/square/finch/stats"
+)
+
+// maxUnseen limits the number of heartbeats written but not yet seen on the
+// replica. If the replica stops replicating, the probe keeps the oldest ones
+// because they determine the current lag.
+const maxUnseen = 100000
+
+// Probe measures replica-visible lag. The writer updates a single heartbeat row
+// on the source with the current time (microseconds) every lag.freq, and the
+// reader polls the replica at the same frequency. When the reader sees a heartbeat,
+// the lag sample is the time between writing it and seeing it. Both use this
+// process's clock, so clock skew between source and replica does not matter.
+//
+// Probe is a stats.Sampler: each interval, it reports the current lag and the
+// max lag during the interval (milliseconds). Current lag is the last sample
+// unless the replica hasn't seen heartbeats for longer, which happens when
+// replication is stopped or very far behind.
+type Probe struct {
+	source  *sql.DB
+	replica *sql.DB
+	table   string
+	freq    time.Duration
+	stop    context.CancelFunc
+	done    *sync.WaitGroup
+	// --
+	*sync.Mutex
+	unseen []int64 // heartbeats written but not yet seen on replica, oldest first
+	last   int64   // last lag sample (μs)
+	max    int64   // max lag this interval (μs)
+	errs   uint    // reader and writer errors (logged once)
+}
+
+var _ stats.Sampler = &Probe{}
+
+func NewProbe(cfg config.Lag, source, replica *sql.DB) *Probe {
+	freq, _ := time.ParseDuration(cfg.Freq) // already validated
+	return &Probe{
+		source:  source,
+		replica: replica,
+		table:   cfg.Table,
+		freq:    freq,
+		done:    &sync.WaitGroup{},
+		Mutex:   &sync.Mutex{},
+		unseen:  []int64{},
+	}
+}
+
+// Prepare creates the heartbeat database and table on the source if they
+// do not exist.
+func (p *Probe) Prepare(ctx context.Context) error {
+	db := strings.SplitN(p.table, ".", 2)[0] // validated: db.tbl
+	queries := []string{
+		"CREATE DATABASE IF NOT EXISTS " + db,
+		"CREATE TABLE IF NOT EXISTS " + p.table + " (id tinyint unsigned NOT NULL PRIMARY KEY, ts bigint NOT NULL)",
+	}
+	for _, q := range queries {
+		finch.Debug(q)
+		if _, err := p.source.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("%s: %s", q, err)
+		}
+	}
+	return nil
+}
+
+// Start starts the heartbeat writer and reader. They run until Stop is called.
+func (p *Probe) Start(ctx context.Context) {
+	ctx, p.stop = context.WithCancel(ctx)
+	p.done.Add(2)
+	go p.write(ctx)
+	go p.read(ctx)
+}
+
+// Stop stops the heartbeat writer and reader, if started, and closes their
+// connections.
+func (p *Probe) Stop() {
+	if p.stop != nil {
+		p.stop()
+		p.done.Wait()
+	}
+	p.source.Close()
+	p.replica.Close()
+}
+
+// Sample returns the current and max lag (milliseconds) and resets the max.
+func (p *Probe) Sample() []stats.Metric {
+	return p.sample(time.Now().UnixMicro())
+}
+
+func (p *Probe) write(ctx context.Context) {
+	defer p.done.Done()
+	q := "INSERT INTO " + p.table + " (id, ts) VALUES (1, ?) ON DUPLICATE KEY UPDATE ts = ?"
+	t := time.NewTicker(p.freq)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		// Save ts before writing, else a fast replica read can see the heartbeat
+		// before it's saved, and it'd be unseen until the next one is seen
+		ts := time.Now().UnixMicro()
+		p.wrote(ts)
+		if _, err := p.source.ExecContext(ctx, q, ts, ts); err != nil {
+			p.unwrote(ts)
+			p.error("heartbeat write", err)
+			continue
+		}
+	}
+}
+
+func (p *Probe) read(ctx context.Context) {
+	defer p.done.Done()
+	q := "SELECT ts FROM " + p.table + " WHERE id = 1"
+	t := time.NewTicker(p.freq)
+	defer t.Stop()
+	var ts int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		err := p.replica.QueryRowContext(ctx, q).Scan(&ts)
+		if err != nil {
+			if err != sql.ErrNoRows { // no rows = first heartbeat not replicated yet
+				p.error("heartbeat read", err)
+			}
+			continue
+		}
+		p.saw(ts, time.Now().UnixMicro())
+	}
+}
+
+// wrote saves heartbeat ts as written on the source. It's called before the
+// write (see write), so unwrote removes ts if the write fails.
+func (p *Probe) wrote(ts int64) {
+	p.Lock()
+	defer p.Unlock()
+	if len(p.unseen) < maxUnseen {
+		p.unseen = append(p.unseen, ts)
+	}
+}
+
+// unwrote removes heartbeat ts saved by wrote when the write fails.
+func (p *Probe) unwrote(ts int64) {
+	p.Lock()
+	defer p.Unlock()
+	if n := len(p.unseen); n > 0 && p.unseen[n-1] == ts {
+		p.unseen = p.unseen[:n-1]
+	}
+}
+
+// saw removes heartbeats up to ts that are visible on the replica at now.
+// Only heartbeat ts is sampled: older heartbeats were overwritten before the
+// reader saw them, so their lag is unknown.
+func (p *Probe) saw(ts, now int64) {
+	p.Lock()
+	defer p.Unlock()
+	n := 0
+	for n < len(p.unseen) && p.unseen[n] <= ts {
+		n++
+	}
+	if n == 0 || p.unseen[n-1] != ts {
+		// Heartbeat from a previous run, or already seen
+		p.unseen = p.unseen[n:]
+		return
+	}
+	p.unseen = p.unseen[n:]
+	p.last = now - ts
+	if p.last > p.max {
+		p.max = p.last
+	}
+}
+
+func (p *Probe) sample(now int64) []stats.Metric {
+	p.Lock()
+	defer p.Unlock()
+	cur := p.last
+	if len(p.unseen) > 0 {
+		// The oldest unseen heartbeat is behind by at least this much. Subtract
+		// freq because the reader polls at that frequency, so a heartbeat can be
+		// unseen for that long without any lag.
+		if d := now - p.unseen[0] - p.freq.Microseconds(); d > cur {
+			cur = d
+		}
+	}
+	if cur > p.max {
+		p.max = cur
+	}
+	m := []stats.Metric{
+		{Name: "lag_ms", Value: ms(cur)},
+		{Name: "lag_max_ms", Value: ms(p.max)},
+	}
+	p.max = 0
+	return m
+}
+
+func (p *Probe) error(what string, err error) {
+	p.Lock()
+	p.errs++
+	n := p.errs
+	p.Unlock()
+	if n == 1 {
+		log.Printf("Lag probe %s error (logged once): %s", what, err)
+	} else {
+		finch.Debug("lag probe %s error: %s", what, err)
+	}
+}
+
+// ms returns microseconds as milliseconds with 0.1 ms precision.
+func ms(us int64) float64 {
+	return float64(us/100) / 10
+}
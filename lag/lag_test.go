@@ -0,0 +1,84 @@
+// Copyright 2024 Block, Inc.
+
+package lag
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/square/finch/config"
+	"github.com/square/finch/stats"
+)
+
+func TestProbe_Lag(t *testing.T) {
+	p := NewProbe(config.Lag{Freq: "100ms", Table: "finch.heartbeat"}, nil, nil)
+
+	// No heartbeats yet: zero lag
+	got := p.sample(1000000)
+	expect := []stats.Metric{{Name: "lag_ms", Value: 0}, {Name: "lag_max_ms", Value: 0}}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Heartbeats at 1.0s and 1.1s; reader sees 1.1s at 1.15s, so 1.0s was
+	// overwritten and not sampled: lag is 50ms
+	p.wrote(1000000)
+	p.wrote(1100000)
+	p.saw(1100000, 1150000)
+	p.saw(1100000, 1250000) // already seen, not sampled again
+	if len(p.unseen) != 0 {
+		t.Errorf("unseen = %v, expected none", p.unseen)
+	}
+	got = p.sample(1260000)
+	expect = []stats.Metric{{Name: "lag_ms", Value: 50}, {Name: "lag_max_ms", Value: 50}}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Replica stops: heartbeats written but not seen, so current lag grows from
+	// the oldest unseen (1.2s) minus freq (100ms)
+	p.wrote(1200000)
+	p.wrote(1300000)
+	p.wrote(1400000)
+	got = p.sample(2200000)
+	expect = []stats.Metric{{Name: "lag_ms", Value: 900}, {Name: "lag_max_ms", Value: 900}}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Replica catches up: max was reset by the last sample
+	p.saw(1400000, 2210500)
+	got = p.sample(2300000)
+	expect = []stats.Metric{{Name: "lag_ms", Value: 810.5}, {Name: "lag_max_ms", Value: 810.5}}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestProbe_Unwrote(t *testing.T) {
+	p := NewProbe(config.Lag{Freq: "100ms", Table: "finch.heartbeat"}, nil, nil)
+
+	// Heartbeat saved before write, and write fails: not unseen, so no lag
+	p.wrote(1000000)
+	p.unwrote(1000000)
+	if len(p.unseen) != 0 {
+		t.Errorf("unseen = %v, expected none", p.unseen)
+	}
+	got := p.sample(2000000)
+	expect := []stats.Metric{{Name: "lag_ms", Value: 0}, {Name: "lag_max_ms", Value: 0}}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Replica read can see the heartbeat right after it's written because
+	// it's saved before the write
+	p.wrote(2000000)
+	p.saw(2000000, 2000500)
+	p.unwrote(2000000) // no-op: not saved anymore
+	got = p.sample(2100000)
+	expect = []stats.Metric{{Name: "lag_ms", Value: 0.5}, {Name: "lag_max_ms", Value: 0.5}}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2024 Block, Inc.
+
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/square/finch"
+	"github.com/square/finch/client"
+	"github.com/square/finch/config"
+	"github.com/square/finch/trx"
+)
+
+const digestTable = "performance_schema.events_statements_summary_by_digest"
+
+// reFormatVerb matches fmt verbs in trx.Statement.Query for data keys (@d)
+// when the statement is not prepared: '%s', %d, etc.
+var reFormatVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+// digestable returns a query for STATEMENT_DIGEST that has the same digest as
+// the queries the statement executes. Data keys are fmt verbs (or ? if prepared),
+// which don't parse, so they're replaced with a literal because the digest
+// replaces literals with ? anyway.
+func digestable(s *trx.Statement) string {
+	q := reFormatVerb.ReplaceAllStringFunc(s.Query, func(v string) string {
+		if v == "%%" {
+			return "%"
+		}
+		return "0"
+	})
+	if s.Prepare {
+		q = strings.ReplaceAll(q, "?", "0")
+	}
+	return q
+}
+
+// digest is one row from the digest table summed for all schemas.
+type digest struct {
+	n             uint64
+	timer         uint64 // picoseconds
+	rowsExamined  uint64
+	rowsSent      uint64
+	rowsAffected  uint64
+	tmpTables     uint64
+	tmpDiskTables uint64
+	sortRows      uint64
+}
+
+func (d *digest) add(d2 digest) {
+	d.n += d2.n
+	d.timer += d2.timer
+	d.rowsExamined += d2.rowsExamined
+	d.rowsSent += d2.rowsSent
+	d.rowsAffected += d2.rowsAffected
+	d.tmpTables += d2.tmpTables
+	d.tmpDiskTables += d2.tmpDiskTables
+	d.sortRows += d2.sortRows
+}
+
+func (d *digest) sub(d2 digest) {
+	d.n -= d2.n
+	d.timer -= d2.timer
+	d.rowsExamined -= d2.rowsExamined
+	d.rowsSent -= d2.rowsSent
+	d.rowsAffected -= d2.rowsAffected
+	d.tmpTables -= d2.tmpTables
+	d.tmpDiskTables -= d2.tmpDiskTables
+	d.sortRows -= d2.sortRows
+}
+
+// digestRow is one line of the report: a digest and the trx statements that
+// have it. Statements with the same digest, like BEGIN and COMMIT in different
+// trx, are one line because the server does not distinguish them.
+type digestRow struct {
+	stmts  []string // trx:n
+	query  string   // first statement
+	digest string   // "" if STATEMENT_DIGEST returned NULL
+	client client.StatementTime
+	server digest
+}
+
+// Digests reports server-side stats from the Performance Schema statement digest
+// table for the trx statements in a stage. At stage start, the table is truncated
+// if permitted (config.stats.digests.truncate) or else read for deltas. At stage
+// end, the table is read again and each digest is joined to the trx statements that
+// have the same digest (STATEMENT_DIGEST) and their client-side response time.
+type Digests struct {
+	db       *sql.DB
+	truncate bool
+	set      *trx.Set
+	digestOf map[*trx.Statement]string
+	before   map[string]digest // nil if truncated
+}
+
+func NewDigests(cfg config.Digests, db *sql.DB, set *trx.Set) *Digests {
+	return &Digests{
+		db:       db,
+		truncate: config.True(cfg.Truncate),
+		set:      set,
+		digestOf: map[*trx.Statement]string{},
+	}
+}
+
+// Prepare checks that the digest table is readable and computes the digest of
+// every trx statement, which requires MySQL 8.0.
+func (d *Digests) Prepare(ctx context.Context) error {
+	var n uint
+	if err := d.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+digestTable).Scan(&n); err != nil {
+		return fmt.Errorf("cannot read %s (is the Performance Schema enabled?): %s", digestTable, err)
+	}
+	for _, trxName := range d.set.Order {
+		for _, s := range d.set.Statements[trxName] {
+			var digest sql.NullString
+			if err := d.db.QueryRowContext(ctx, "SELECT STATEMENT_DIGEST(?)", digestable(s)).Scan(&digest); err != nil {
+				return fmt.Errorf("STATEMENT_DIGEST (requires MySQL 8.0): %s", err)
+			}
+			if !digest.Valid {
+				finch.Debug("no digest: %s", s.Query)
+				continue
+			}
+			d.digestOf[s] = digest.String
+		}
+	}
+	return nil
+}
+
+// Start truncates the digest table, or reads it if truncating is disabled or
+// not permitted. It's called immediately before clients start.
+func (d *Digests) Start(ctx context.Context) {
+	if d.truncate {
+		_, err := d.db.ExecContext(ctx, "TRUNCATE TABLE "+digestTable)
+		if err == nil {
+			return
+		}
+		log.Printf("Cannot truncate %s, reporting deltas: %s", digestTable, err)
+	}
+	before, err := d.read(ctx)
+	if err != nil {
+		log.Printf("Error reading %s: %s", digestTable, err)
+		return
+	}
+	d.before = before
+}
+
+// Stop closes the connection to MySQL.
+func (d *Digests) Stop() {
+	d.db.Close()
+}
+
+// Report prints the digest report to w. Client-side response times are summed
+// from all clients for each statement.
+func (d *Digests) Report(ctx context.Context, w io.Writer, times map[*trx.Statement]client.StatementTime) error {
+	after, err := d.read(ctx)
+	if err != nil {
+		return err
+	}
+	rows := join(d.set, d.digestOf, d.before, after, times)
+
+	tw := tabwriter.NewWriter(w, 1, 0, 1, ' ', tabwriter.AlignRight|tabwriter.Debug)
+	fmt.Fprintln(tw, "trx\tclient_n\tclient_avg\tserver_n\tserver_avg\trows_examined\trows_sent\trows_affected\ttmp_tables\ttmp_disk_tables\tsort_rows\tquery")
+	for _, r := range rows {
+		line := fmt.Sprintf("%s\t%d\t%d\t", strings.Join(r.stmts, ","), r.client.N, avg(r.client.Sum, r.client.N))
+		if r.digest == "" {
+			line += "-\t-\t-\t-\t-\t-\t-\t-\t"
+		} else {
+			line += fmt.Sprintf("%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t",
+				r.server.n,
+				avg(int64(r.server.timer/1000000), r.server.n), // ps -> μs
+				r.server.rowsExamined,
+				r.server.rowsSent,
+				r.server.rowsAffected,
+				r.server.tmpTables,
+				r.server.tmpDiskTables,
+				r.server.sortRows,
+			)
+		}
+		fmt.Fprintln(tw, line+r.query)
+	}
+	return tw.Flush()
+}
+
+// join returns one row per digest for the trx statements in set order. Server
+// values are after minus before (if not truncated).
+func join(set *trx.Set, digestOf map[*trx.Statement]string, before, after map[string]digest, times map[*trx.Statement]client.StatementTime) []*digestRow {
+	rows := []*digestRow{}
+	seen := map[string]*digestRow{}
+	for _, trxName := range set.Order {
+		for i, s := range set.Statements[trxName] {
+			stmt := fmt.Sprintf("%s:%d", trxName, i+1)
+			t := times[s]
+			dg := digestOf[s]
+			if r, ok := seen[dg]; ok && dg != "" {
+				r.stmts = append(r.stmts, stmt)
+				r.client.N += t.N
+				r.client.Sum += t.Sum
+				continue
+			}
+			r := &digestRow{
+				stmts:  []string{stmt},
+				query:  shortQuery(s.Query),
+				digest: dg,
+				client: t,
+			}
+			if dg != "" {
+				r.server = after[dg]
+				if b, ok := before[dg]; ok && b.n <= r.server.n { // else reset during stage
+					r.server.sub(b)
+				}
+				seen[dg] = r
+			}
+			rows = append(rows, r)
+		}
+	}
+	return rows
+}
+
+// read returns the digest table keyed on digest.
+func (d *Digests) read(ctx context.Context) (map[string]digest, error) {
+	q := "SELECT DIGEST, COUNT_STAR, SUM_TIMER_WAIT, SUM_ROWS_EXAMINED, SUM_ROWS_SENT, SUM_ROWS_AFFECTED, SUM_CREATED_TMP_TABLES, SUM_CREATED_TMP_DISK_TABLES, SUM_SORT_ROWS FROM " + digestTable + " WHERE DIGEST IS NOT NULL"
+	rows, err := d.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	digests := map[string]digest{}
+	var k string
+	for rows.Next() {
+		var v digest
+		if err := rows.Scan(&k, &v.n, &v.timer, &v.rowsExamined, &v.rowsSent, &v.rowsAffected, &v.tmpTables, &v.tmpDiskTables, &v.sortRows); err != nil {
+			return nil, err
+		}
+		sum := digests[k] // same digest in different schemas
+		sum.add(v)
+		digests[k] = sum
+	}
+	return digests, rows.Err()
+}
+
+func avg(sum int64, n uint64) int64 {
+	if n == 0 {
+		return 0
+	}
+	return sum / int64(n)
+}
+
+// shortQuery returns the query on one line, truncated to 50 characters.
+func shortQuery(q string) string {
+	q = strings.Join(strings.Fields(q), " ")
+	if len(q) > 50 {
+		q = q[:47] + "..."
+	}
+	return q
+}
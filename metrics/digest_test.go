@@ -0,0 +1,56 @@
+// Copyright 2024 Block, Inc.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/square/finch/client"
+	"github.com/square/finch/trx"
+)
+
+func TestDigestable(t *testing.T) {
+	tests := []struct {
+		s      trx.Statement
+		expect string
+	}{
+		{trx.Statement{Query: "SELECT c FROM t WHERE id = %d AND s = '%s'"}, "SELECT c FROM t WHERE id = 0 AND s = '0'"},
+		{trx.Statement{Query: "SELECT c FROM t WHERE c LIKE 'a%%'"}, "SELECT c FROM t WHERE c LIKE 'a%'"},
+		{trx.Statement{Query: "SELECT c FROM t WHERE id = ?", Prepare: true}, "SELECT c FROM t WHERE id = 0"},
+	}
+	for _, test := range tests {
+		if got := digestable(&test.s); got != test.expect {
+			t.Errorf("got '%s', expected '%s'", got, test.expect)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	begin1 := &trx.Statement{Query: "BEGIN"}
+	sel := &trx.Statement{Query: "SELECT c FROM t WHERE id = %d"}
+	begin2 := &trx.Statement{Query: "BEGIN"}
+	set := &trx.Set{
+		Order: []string{"a.sql", "b.sql"},
+		Statements: map[string][]*trx.Statement{
+			"a.sql": {begin1, sel},
+			"b.sql": {begin2},
+		},
+	}
+	digestOf := map[*trx.Statement]string{begin1: "b", begin2: "b", sel: "s"}
+	before := map[string]digest{"s": {n: 10, timer: 10000000}}
+	after := map[string]digest{"b": {n: 5}, "s": {n: 15, timer: 20000000, rowsSent: 5}}
+	times := map[*trx.Statement]client.StatementTime{begin1: {N: 3, Sum: 30}, begin2: {N: 2, Sum: 10}, sel: {N: 5, Sum: 500}}
+
+	deep.CompareUnexportedFields = true
+	defer func() { deep.CompareUnexportedFields = false }()
+	got := join(set, digestOf, before, after, times)
+	expect := []*digestRow{
+		{stmts: []string{"a.sql:1", "b.sql:1"}, query: "BEGIN", digest: "b", client: client.StatementTime{N: 5, Sum: 40}, server: digest{n: 5}},
+		{stmts: []string{"a.sql:2"}, query: "SELECT c FROM t WHERE id = %d", digest: "s", client: client.StatementTime{N: 5, Sum: 500}, server: digest{n: 5, timer: 10000000, rowsSent: 5}},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
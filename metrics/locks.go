@@ -0,0 +1,343 @@
+// Copyright 2024 Block, Inc.
+
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/square/finch"
+	"github.com/square/finch/client"
+	"github.com/square/finch/config"
+	"github.com/square/finch/trx"
+)
+
+const (
+	kindDeadlock = "deadlock"
+	kindLockWait = "lock wait"
+)
+
+// lockWaitsQuery returns current lock waits: the requested and blocking lock,
+// and the current statement of both threads, if known.
+const lockWaitsQuery = `SELECT
+  r.OBJECT_SCHEMA, r.OBJECT_NAME, COALESCE(r.INDEX_NAME, ''), r.LOCK_MODE, b.LOCK_MODE,
+  COALESCE(rs.SQL_TEXT, ''), COALESCE(bs.SQL_TEXT, '')
+FROM performance_schema.data_lock_waits w
+  JOIN performance_schema.data_locks r ON r.ENGINE_LOCK_ID = w.REQUESTING_ENGINE_LOCK_ID
+  JOIN performance_schema.data_locks b ON b.ENGINE_LOCK_ID = w.BLOCKING_ENGINE_LOCK_ID
+  LEFT JOIN performance_schema.events_statements_current rs ON rs.THREAD_ID = w.REQUESTING_THREAD_ID
+  LEFT JOIN performance_schema.events_statements_current bs ON bs.THREAD_ID = w.BLOCKING_THREAD_ID`
+
+// reTrxId matches the trx id in InnoDB lock lines, which is removed so the
+// same lock in different trx has the same signature.
+var reTrxId = regexp.MustCompile(` trx id \d+`)
+
+// hotLock is a deadlock or lock wait deduplicated by signature: the locks involved.
+type hotLock struct {
+	kind       string
+	signature  string
+	n          uint     // times captured
+	statements []string // example conflicting statements from the first capture
+}
+
+// deadlock is the LATEST DETECTED DEADLOCK section of SHOW ENGINE INNODB STATUS.
+type deadlock struct {
+	id         string // timestamp and thread line, changes for each deadlock
+	locks      []string
+	statements []string
+}
+
+// parseDeadlock returns the latest deadlock in the output of SHOW ENGINE INNODB
+// STATUS, or nil if there is none.
+func parseDeadlock(status string) *deadlock {
+	sc := bufio.NewScanner(strings.NewReader(status))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() { // find section
+		if sc.Text() == "LATEST DETECTED DEADLOCK" {
+			break
+		}
+	}
+	if !sc.Scan() || !sc.Scan() { // ------ line after header, then timestamp line
+		return nil
+	}
+	dl := &deadlock{id: sc.Text()}
+	seen := map[string]bool{}
+	var query []string
+	inQuery := false
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "---") { // next section
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, "MySQL thread id"):
+			inQuery = true // query is on the following line(s)
+			continue
+		case inQuery && (line == "" || strings.HasPrefix(line, "*** ")):
+			dl.statements = append(dl.statements, fmt.Sprintf("(%d) %s", len(dl.statements)+1, strings.Join(query, " ")))
+			query = nil
+			inQuery = false
+		case inQuery:
+			query = append(query, strings.TrimSpace(line))
+			continue
+		}
+		var lock string
+		if strings.HasPrefix(line, "RECORD LOCKS ") {
+			if p := strings.Index(line, " index "); p > 0 {
+				lock = line[p+1:]
+			}
+		} else if strings.HasPrefix(line, "TABLE LOCK ") {
+			lock = line
+		}
+		if lock == "" {
+			continue
+		}
+		lock = reTrxId.ReplaceAllString(lock, "")
+		if !seen[lock] {
+			seen[lock] = true
+			dl.locks = append(dl.locks, lock)
+		}
+	}
+	if len(dl.locks) == 0 {
+		return nil
+	}
+	sort.Strings(dl.locks)
+	return dl
+}
+
+// Locks captures InnoDB lock diagnostics when clients get a deadlock (1213) or
+// lock wait timeout (1205) error: the latest deadlock from SHOW ENGINE INNODB
+// STATUS, and current lock waits from performance_schema.data_lock_waits. Captures
+// are rate limited (config.stats.lock-diagnostics.freq) and deduplicated by lock
+// signature. Report prints a summary at the end of the stage: errors per trx
+// statement and the hot locks with example conflicting statements.
+type Locks struct {
+	db      *sql.DB
+	freq    time.Duration
+	set     *trx.Set
+	errors  chan client.LockError
+	capture chan string // kind
+	stop    context.CancelFunc
+	done    *sync.WaitGroup
+	// --
+	n          map[*trx.Statement]map[uint16]uint // errors per statement
+	hot        map[string]*hotLock                // keyed on kind+signature
+	last       map[string]time.Time               // last capture per kind
+	lastDL     string                             // deadlock.id of last captured deadlock
+	nCaptures  uint
+	captureErr uint
+	*sync.Mutex
+}
+
+func NewLocks(cfg config.LockDiagnostics, db *sql.DB, set *trx.Set) *Locks {
+	freq, _ := time.ParseDuration(cfg.Freq) // already validated
+	return &Locks{
+		db:      db,
+		freq:    freq,
+		set:     set,
+		errors:  make(chan client.LockError, 100),
+		capture: make(chan string, 1),
+		done:    &sync.WaitGroup{},
+		n:       map[*trx.Statement]map[uint16]uint{},
+		hot:     map[string]*hotLock{},
+		last:    map[string]time.Time{},
+		Mutex:   &sync.Mutex{},
+	}
+}
+
+// Prepare checks that lock waits are readable, which requires MySQL 8.0.
+func (l *Locks) Prepare(ctx context.Context) error {
+	var n uint
+	if err := l.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM performance_schema.data_lock_waits").Scan(&n); err != nil {
+		return fmt.Errorf("cannot read performance_schema.data_lock_waits (requires MySQL 8.0): %s", err)
+	}
+	return nil
+}
+
+// Errors returns the channel for Client.LockErrors.
+func (l *Locks) Errors() chan<- client.LockError {
+	return l.errors
+}
+
+// Start starts capturing diagnostics on errors until Stop is called.
+func (l *Locks) Start(ctx context.Context) {
+	ctx, l.stop = context.WithCancel(ctx)
+	l.done.Add(2)
+	go l.count(ctx)
+	go l.captures(ctx)
+}
+
+// Stop stops capturing diagnostics and closes the connection to MySQL.
+func (l *Locks) Stop() {
+	if l.stop != nil {
+		l.stop()
+		l.done.Wait()
+	}
+	l.db.Close()
+}
+
+// count counts errors and triggers rate-limited captures. Captures are done
+// in another goroutine so errors are counted (and clients don't block) while
+// capturing.
+func (l *Locks) count(ctx context.Context) {
+	defer l.done.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-l.errors:
+			l.Lock()
+			if l.n[e.Statement] == nil {
+				l.n[e.Statement] = map[uint16]uint{}
+			}
+			l.n[e.Statement][e.Code]++
+			l.Unlock()
+
+			kind := kindLockWait
+			if e.Code == 1213 {
+				kind = kindDeadlock
+			}
+			if time.Now().Sub(l.last[kind]) < l.freq {
+				continue // rate limited
+			}
+			select {
+			case l.capture <- kind:
+				l.last[kind] = time.Now()
+			default: // capture in progress
+			}
+		}
+	}
+}
+
+func (l *Locks) captures(ctx context.Context) {
+	defer l.done.Done()
+	for {
+		var kind string
+		select {
+		case <-ctx.Done():
+			return
+		case kind = <-l.capture:
+		}
+		var err error
+		if kind == kindDeadlock {
+			err = l.captureDeadlock(ctx)
+		} else {
+			err = l.captureLockWaits(ctx)
+		}
+		if err != nil && ctx.Err() == nil {
+			l.captureErr++
+			if l.captureErr == 1 {
+				log.Printf("Lock diagnostics error (logged once): %s", err)
+			} else {
+				finch.Debug("lock diagnostics error: %s", err)
+			}
+		}
+	}
+}
+
+func (l *Locks) captureDeadlock(ctx context.Context) error {
+	var typ, name, status string
+	if err := l.db.QueryRowContext(ctx, "SHOW ENGINE INNODB STATUS").Scan(&typ, &name, &status); err != nil {
+		return err
+	}
+	dl := parseDeadlock(status)
+	l.Lock()
+	defer l.Unlock()
+	l.nCaptures++
+	if dl == nil || dl.id == l.lastDL {
+		return nil // no deadlock or same as last capture
+	}
+	l.lastDL = dl.id
+	l.add(kindDeadlock, strings.Join(dl.locks, "\n"), dl.statements)
+	return nil
+}
+
+func (l *Locks) captureLockWaits(ctx context.Context) error {
+	rows, err := l.db.QueryContext(ctx, lockWaitsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	l.Lock()
+	defer l.Unlock()
+	l.nCaptures++
+	var db, tbl, index, reqMode, blkMode, reqQuery, blkQuery string
+	for rows.Next() {
+		if err := rows.Scan(&db, &tbl, &index, &reqMode, &blkMode, &reqQuery, &blkQuery); err != nil {
+			return err
+		}
+		sig := fmt.Sprintf("index %s of table `%s`.`%s` %s waiting for %s", index, db, tbl, reqMode, blkMode)
+		l.add(kindLockWait, sig, []string{"waiting: " + reqQuery, "blocking: " + blkQuery})
+	}
+	return rows.Err()
+}
+
+// add adds a captured deadlock or lock wait. Caller must lock l.
+func (l *Locks) add(kind, sig string, statements []string) {
+	k := kind + sig
+	if h, ok := l.hot[k]; ok {
+		h.n++
+		return
+	}
+	l.hot[k] = &hotLock{kind: kind, signature: sig, n: 1, statements: statements}
+}
+
+// Report prints the lock diagnostics summary to w. It's called after Stop.
+func (l *Locks) Report(w io.Writer) {
+	l.Lock()
+	defer l.Unlock()
+
+	var nDL, nLW uint
+	for _, codes := range l.n {
+		nDL += codes[1213]
+		nLW += codes[1205]
+	}
+	fmt.Fprintf(w, "%d deadlocks (1213), %d lock wait timeouts (1205), %d captures\n", nDL, nLW, l.nCaptures)
+	if nDL+nLW == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\nErrors by statement:")
+	for _, trxName := range l.set.Order {
+		for i, s := range l.set.Statements[trxName] {
+			for _, code := range []uint16{1213, 1205} {
+				if n := l.n[s][code]; n > 0 {
+					fmt.Fprintf(w, "  %s:%d  %d  %d  %s\n", trxName, i+1, code, n, shortQuery(s.Query))
+				}
+			}
+		}
+	}
+
+	if len(l.hot) == 0 {
+		return
+	}
+	hot := make([]*hotLock, 0, len(l.hot))
+	for _, h := range l.hot {
+		hot = append(hot, h)
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].n == hot[j].n {
+			return hot[i].signature < hot[j].signature
+		}
+		return hot[i].n > hot[j].n
+	})
+	fmt.Fprintln(w, "\nHot locks (most captured first):")
+	for _, h := range hot {
+		fmt.Fprintf(w, "  %s, captured %d times:\n", h.kind, h.n)
+		for _, lock := range strings.Split(h.signature, "\n") {
+			fmt.Fprintf(w, "    %s\n", lock)
+		}
+		for _, q := range h.statements {
+			fmt.Fprintf(w, "      %s\n", q)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Block, Inc.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+var innodbStatus = `
+=====================================
+2024-03-01 10:00:05 0x7f0c1c0e5700 INNODB MONITOR OUTPUT
+=====================================
+------------------------
+LATEST DETECTED DEADLOCK
+------------------------
+2024-03-01 10:00:01 0x7f0c1c0e5700
+*** (1) TRANSACTION:
+TRANSACTION 5001, ACTIVE 0 sec starting index read
+mysql tables in use 1, locked 1
+LOCK WAIT 3 lock struct(s), heap size 1128, 2 row lock(s)
+MySQL thread id 11, OS thread handle 139, query id 201 localhost finch updating
+UPDATE t SET c = c + 1 WHERE id = 2
+
+*** (1) HOLDS THE LOCK(S):
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`finch`.`t`" + ` trx id 5001 lock_mode X locks rec but not gap
+Record lock, heap no 2 PHYSICAL RECORD: n_fields 4; compact format; info bits 0
+
+*** (1) WAITING FOR THIS LOCK TO BE GRANTED:
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`finch`.`t`" + ` trx id 5001 lock_mode X locks rec but not gap waiting
+Record lock, heap no 3 PHYSICAL RECORD: n_fields 4; compact format; info bits 0
+
+*** (2) TRANSACTION:
+TRANSACTION 5002, ACTIVE 0 sec starting index read
+mysql tables in use 1, locked 1
+LOCK WAIT 3 lock struct(s), heap size 1128, 2 row lock(s)
+MySQL thread id 12, OS thread handle 140, query id 202 localhost finch updating
+UPDATE t SET c = c + 1
+  WHERE id = 1
+
+*** (2) HOLDS THE LOCK(S):
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`finch`.`t`" + ` trx id 5002 lock_mode X locks rec but not gap
+Record lock, heap no 3 PHYSICAL RECORD: n_fields 4; compact format; info bits 0
+
+*** (2) WAITING FOR THIS LOCK TO BE GRANTED:
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`finch`.`t`" + ` trx id 5002 lock_mode X locks rec but not gap waiting
+Record lock, heap no 2 PHYSICAL RECORD: n_fields 4; compact format; info bits 0
+
+*** WE ROLL BACK TRANSACTION (2)
+------------
+TRANSACTIONS
+------------
+Trx id counter 5003
+`
+
+func TestParseDeadlock(t *testing.T) {
+	got := parseDeadlock(innodbStatus)
+	expect := &deadlock{
+		id: "2024-03-01 10:00:01 0x7f0c1c0e5700",
+		locks: []string{
+			"index PRIMARY of table `finch`.`t` lock_mode X locks rec but not gap",
+			"index PRIMARY of table `finch`.`t` lock_mode X locks rec but not gap waiting",
+		},
+		statements: []string{
+			"(1) UPDATE t SET c = c + 1 WHERE id = 2",
+			"(2) UPDATE t SET c = c + 1 WHERE id = 1",
+		},
+	}
+	deep.CompareUnexportedFields = true
+	defer func() { deep.CompareUnexportedFields = false }()
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	if dl := parseDeadlock("=====\nTRANSACTIONS\n------------\n"); dl != nil {
+		t.Errorf("got deadlock %+v, expected nil when there is no deadlock", dl)
+	}
+}
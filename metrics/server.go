@@ -0,0 +1,259 @@
+// Copyright 2024 Block, Inc.
+
+// Package metrics provides MySQL server metrics sampled each stats interval.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/square/finch"
+	"github.com/square/finch/config"
+	"github.com/square/finch/stats"
+)
+
+// timeout is the max time to read metrics. It's short because Sample is
+// called by the stats collector at the end of each interval.
+const timeout = 2 * time.Second
+
+// statusGauges are SHOW GLOBAL STATUS variables (lowercase) that are current
+// values, not counters, so they're reported as-is instead of deltas.
+var statusGauges = []string{
+	"threads_",
+	"open_",
+	"innodb_buffer_pool_pages_",
+	"innodb_buffer_pool_bytes_",
+	"innodb_row_lock_current_waits",
+	"innodb_num_open_files",
+	"max_used_connections",
+	"uptime",
+}
+
+// isStatusGauge returns true if SHOW GLOBAL STATUS variable name (lowercase)
+// is a gauge. Names ending with "_" in statusGauges are prefixes.
+func isStatusGauge(name string) bool {
+	for _, g := range statusGauges {
+		if name == g || (strings.HasSuffix(g, "_") && strings.HasPrefix(name, g)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Server samples MySQL global status variables and InnoDB metrics. It's a
+// stats.Sampler: each interval, it reports the delta of counters and the
+// current value of gauges, like Threads_running and trx_rseg_history_len.
+// Metrics are reported in the order configured: status, then InnoDB metrics.
+type Server struct {
+	db     *sql.DB
+	status []string        // SHOW GLOBAL STATUS variable names
+	innodb []string        // INNODB_METRICS names
+	gauge  map[string]bool // lowercase name
+	last   map[string]float64
+	errs   uint
+}
+
+var _ stats.Sampler = &Server{}
+
+func NewServer(cfg config.ServerMetrics, db *sql.DB) *Server {
+	return &Server{
+		db:     db,
+		status: cfg.Status,
+		innodb: cfg.InnoDBMetrics,
+		gauge:  map[string]bool{},
+		last:   map[string]float64{},
+	}
+}
+
+// Prepare validates that all metrics exist, are numeric, and, for InnoDB metrics,
+// are enabled.
+func (s *Server) Prepare(ctx context.Context) error {
+	for _, name := range s.status {
+		if isStatusGauge(strings.ToLower(name)) {
+			s.gauge[strings.ToLower(name)] = true
+		}
+	}
+
+	if len(s.innodb) > 0 {
+		q := "SELECT NAME, STATUS, TYPE FROM information_schema.INNODB_METRICS WHERE NAME IN (" + placeholders(len(s.innodb)) + ")"
+		rows, err := s.db.QueryContext(ctx, q, args(s.innodb)...)
+		if err != nil {
+			return fmt.Errorf("%s: %s", q, err)
+		}
+		defer rows.Close()
+		enabled := map[string]bool{}
+		var name, status, typ string
+		for rows.Next() {
+			if err := rows.Scan(&name, &status, &typ); err != nil {
+				return err
+			}
+			name = strings.ToLower(name)
+			enabled[name] = strings.ToLower(status) == "enabled"
+			if strings.ToLower(typ) == "value" {
+				s.gauge[name] = true
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, name := range s.innodb {
+			on, ok := enabled[strings.ToLower(name)]
+			if !ok {
+				return fmt.Errorf("innodb-metrics: %s does not exist", name)
+			}
+			if !on {
+				return fmt.Errorf("innodb-metrics: %s is not enabled; see innodb_monitor_enable", name)
+			}
+		}
+	}
+
+	vals, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range s.status {
+		if _, ok := vals[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("status: %s does not exist or is not numeric", name)
+		}
+	}
+	return nil
+}
+
+// Start reads the initial values for deltas. It's called immediately before
+// the stats collector starts.
+func (s *Server) Start(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	vals, err := s.read(ctx)
+	if err != nil {
+		s.error(err)
+		return
+	}
+	s.last = vals
+}
+
+// Stop closes the connection to MySQL.
+func (s *Server) Stop() {
+	s.db.Close()
+}
+
+// Sample returns the metrics for the interval. If reading metrics fails, the
+// values are zero and the next interval has the deltas for both intervals.
+func (s *Server) Sample() []stats.Metric {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	vals, err := s.read(ctx)
+	if err != nil {
+		s.error(err)
+		vals = nil
+	}
+	names := append(append([]string{}, s.status...), s.innodb...)
+	m := deltas(names, s.gauge, s.last, vals)
+	if vals != nil {
+		s.last = vals
+	}
+	return m
+}
+
+// deltas returns a metric for each name: the current value of gauges, or the
+// difference between cur and last for counters. If cur is nil (error reading
+// metrics), all values are zero.
+func deltas(names []string, gauge map[string]bool, last, cur map[string]float64) []stats.Metric {
+	m := make([]stats.Metric, len(names))
+	for i, name := range names {
+		m[i].Name = name
+		if cur == nil {
+			continue
+		}
+		k := strings.ToLower(name)
+		if gauge[k] {
+			m[i].Value = cur[k]
+		} else {
+			m[i].Value = cur[k] - last[k]
+		}
+	}
+	return m
+}
+
+// read returns the current values of all metrics keyed on lowercase name.
+func (s *Server) read(ctx context.Context) (map[string]float64, error) {
+	vals := map[string]float64{}
+
+	if len(s.status) > 0 {
+		want := map[string]bool{}
+		for _, name := range s.status {
+			want[strings.ToLower(name)] = true
+		}
+		rows, err := s.db.QueryContext(ctx, "SHOW GLOBAL STATUS")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var name, val string
+		for rows.Next() {
+			if err := rows.Scan(&name, &val); err != nil {
+				return nil, err
+			}
+			name = strings.ToLower(name)
+			if !want[name] {
+				continue
+			}
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				continue // not numeric, like Ssl_cipher
+			}
+			vals[name] = f
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(s.innodb) > 0 {
+		q := "SELECT NAME, COUNT FROM information_schema.INNODB_METRICS WHERE NAME IN (" + placeholders(len(s.innodb)) + ")"
+		rows, err := s.db.QueryContext(ctx, q, args(s.innodb)...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var name string
+		var val int64
+		for rows.Next() {
+			if err := rows.Scan(&name, &val); err != nil {
+				return nil, err
+			}
+			vals[strings.ToLower(name)] = float64(val)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return vals, nil
+}
+
+func (s *Server) error(err error) {
+	s.errs++
+	if s.errs == 1 {
+		log.Printf("Server metrics error (logged once): %s", err)
+	} else {
+		finch.Debug("server metrics error: %s", err)
+	}
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func args(names []string) []interface{} {
+	a := make([]interface{}, len(names))
+	for i := range names {
+		a[i] = names[i]
+	}
+	return a
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 Block, Inc.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/square/finch/stats"
+)
+
+func TestDeltas(t *testing.T) {
+	names := []string{"Innodb_rows_read", "Threads_running", "trx_rseg_history_len"}
+	gauge := map[string]bool{
+		"threads_running":      isStatusGauge("threads_running"),
+		"trx_rseg_history_len": true, // INNODB_METRICS type value
+	}
+	if isStatusGauge("innodb_rows_read") {
+		t.Error("Innodb_rows_read is a gauge, expected counter")
+	}
+
+	last := map[string]float64{"innodb_rows_read": 1000, "threads_running": 4, "trx_rseg_history_len": 50}
+	cur := map[string]float64{"innodb_rows_read": 1500, "threads_running": 2, "trx_rseg_history_len": 80}
+	got := deltas(names, gauge, last, cur)
+	expect := []stats.Metric{
+		{Name: "Innodb_rows_read", Value: 500},
+		{Name: "Threads_running", Value: 2},
+		{Name: "trx_rseg_history_len", Value: 80},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Error reading metrics: same metrics, zero values
+	got = deltas(names, gauge, last, nil)
+	expect = []stats.Metric{
+		{Name: "Innodb_rows_read", Value: 0},
+		{Name: "Threads_running", Value: 0},
+		{Name: "trx_rseg_history_len", Value: 0},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
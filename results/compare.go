@@ -0,0 +1,369 @@
+// Copyright 2024 Block, Inc.
+
+package results
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	h "github.com/dustin/go-humanize"
+
+	"github.com/square/finch/config"
+	"github.com/square/finch/stats"
+)
+
+// ComparePercentiles are the percentiles compared for each event type.
+var ComparePercentiles = []float64{50, 99, 99.9}
+
+// Significance is the p-value below which a difference is significant.
+const Significance = 0.05
+
+// total is the pseudo trx name for all trx combined.
+const total = "(all)"
+
+// stageStats is the stats for one stage from stats.jsonl: all instances combined
+// per interval, and all intervals combined for the whole stage.
+type stageStats struct {
+	name      string
+	intervals []intervalStats
+	all       intervalStats
+}
+
+// intervalStats is total and per-trx stats for one interval, or the whole stage.
+type intervalStats struct {
+	seconds float64
+	trx     map[string]*stats.Stats // including total
+}
+
+func newIntervalStats() intervalStats {
+	return intervalStats{trx: map[string]*stats.Stats{}}
+}
+
+func (in intervalStats) add(trxName string, s *stats.Stats) {
+	if _, ok := in.trx[trxName]; !ok {
+		in.trx[trxName] = stats.NewStats()
+	}
+	in.trx[trxName].Combine(s)
+}
+
+// CompareDirs compares the results bundles in dirs A and B (see compare).
+func CompareDirs(w io.Writer, dirA, dirB string) error {
+	a, err := load(dirA)
+	if err != nil {
+		return err
+	}
+	b, err := load(dirB)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "A: %s\nB: %s\n\n", dirA, dirB)
+	compare(w, a, b)
+	return nil
+}
+
+// load loads the stats of all stages in a results bundle in run order.
+// Stages with stats disabled are not returned.
+func load(dir string) ([]stageStats, error) {
+	var run Run
+	bytes, err := os.ReadFile(filepath.Join(dir, "finch.json"))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, &run); err != nil {
+		return nil, fmt.Errorf("%s: %s", filepath.Join(dir, "finch.json"), err)
+	}
+	all := []stageStats{}
+	for _, stageDir := range run.Stages {
+		file := filepath.Join(dir, stageDir, "stats.jsonl")
+		if !config.FileExists(file) {
+			continue // stats disabled
+		}
+		s, err := loadStats(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		s.name = stageName(stageDir)
+		all = append(all, s)
+	}
+	return all, nil
+}
+
+func loadStats(file string) (stageStats, error) {
+	s := stageStats{all: newIntervalStats()}
+	f, err := os.Open(file)
+	if err != nil {
+		return s, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024) // histograms make long lines
+	for sc.Scan() {
+		var ji stats.JSONInterval
+		if err := json.Unmarshal(sc.Bytes(), &ji); err != nil {
+			return s, err
+		}
+		if len(ji.Instances) == 0 {
+			continue
+		}
+		in := newIntervalStats()
+		in.seconds = ji.Instances[0].Seconds
+		for _, inst := range ji.Instances {
+			in.add(total, inst.Total)
+			for trxName, trxStats := range inst.Trx {
+				in.add(trxName, trxStats)
+			}
+		}
+		s.intervals = append(s.intervals, in)
+		s.all.seconds += in.seconds
+		for trxName, trxStats := range in.trx {
+			s.all.add(trxName, trxStats)
+		}
+	}
+	return s, sc.Err()
+}
+
+// stageName returns the stage name from the stage dir without the NN- prefix
+// (see stageDir) so stages match between runs by name.
+func stageName(dir string) string {
+	if p := strings.Index(dir, "-"); p > 0 {
+		return dir[p+1:]
+	}
+	return dir
+}
+
+// compare prints the difference between stages in two runs, A and B. Stages
+// are matched by name. For each trx and event type, it prints QPS and percentiles
+// for A and B, the delta (B relative to A), and the p-value of Welch's t-test
+// on the per-interval values. A difference is significant (*) if p < Significance.
+func compare(w io.Writer, a, b []stageStats) {
+	byName := map[string]stageStats{}
+	for _, s := range b {
+		byName[s.name] = s
+	}
+	for _, sa := range a {
+		sb, ok := byName[sa.name]
+		if !ok {
+			fmt.Fprintf(w, "# %s: not in B\n\n", sa.name)
+			continue
+		}
+		delete(byName, sa.name)
+		fmt.Fprintf(w, "# %s (A: %d intervals, B: %d intervals)\n", sa.name, len(sa.intervals), len(sb.intervals))
+		compareStage(w, sa, sb)
+		fmt.Fprintln(w)
+	}
+	for _, s := range b { // in run order
+		if _, ok := byName[s.name]; ok {
+			fmt.Fprintf(w, "# %s: not in A\n\n", s.name)
+		}
+	}
+}
+
+func compareStage(w io.Writer, a, b stageStats) {
+	tw := tabwriter.NewWriter(w, 1, 0, 1, ' ', tabwriter.AlignRight|tabwriter.Debug)
+	fmt.Fprintln(tw, "trx\tevent\tmetric\tA\tB\tdelta\tp\t\t")
+	for _, trxName := range trxNames(a.all, b.all) {
+		sa, sb := a.all.trx[trxName], b.all.trx[trxName]
+		if sa == nil || sb == nil {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\t-\t-\t\tnot in both\n", trxName)
+			continue
+		}
+		for _, e := range eventTypes {
+			if sa.N[e.Type] == 0 && sb.N[e.Type] == 0 {
+				continue
+			}
+			// Rate
+			qa := series(a, trxName, e.Type, -1)
+			qb := series(b, trxName, e.Type, -1)
+			line(tw, trxName, e.Name, "QPS", rate(sa.N[e.Type], a.all.seconds), rate(sb.N[e.Type], b.all.seconds), qa, qb)
+
+			// Percentiles
+			pa := sa.Percentiles(e.Type, ComparePercentiles)
+			pb := sb.Percentiles(e.Type, ComparePercentiles)
+			for i, p := range ComparePercentiles {
+				line(tw, trxName, e.Name, pName(p), float64(pa[i]), float64(pb[i]), series(a, trxName, e.Type, i), series(b, trxName, e.Type, i))
+			}
+		}
+	}
+	tw.Flush()
+}
+
+func line(w io.Writer, trxName, event, metric string, a, b float64, sa, sb []float64) {
+	delta := "-"
+	if a > 0 {
+		delta = fmt.Sprintf("%+.1f%%", (b-a)/a*100)
+	}
+	pStr, sig := "-", ""
+	if p, ok := welch(sa, sb); ok {
+		pStr = fmt.Sprintf("%.3f", p)
+		if p < Significance {
+			sig = "*"
+		}
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", trxName, event, metric, h.Comma(int64(a)), h.Comma(int64(b)), delta, pStr, sig)
+}
+
+// series returns the per-interval values of a metric: QPS if p < 0, else
+// ComparePercentiles[p]. Intervals without the event are skipped for percentiles
+// (there's no value) but not for QPS (the value is zero).
+func series(s stageStats, trxName string, eventType byte, p int) []float64 {
+	v := make([]float64, 0, len(s.intervals))
+	for _, in := range s.intervals {
+		st := in.trx[trxName]
+		if p < 0 {
+			var n uint64
+			if st != nil {
+				n = st.N[eventType]
+			}
+			v = append(v, rate(n, in.seconds))
+			continue
+		}
+		if st == nil || st.N[eventType] == 0 {
+			continue
+		}
+		v = append(v, float64(st.Percentiles(eventType, ComparePercentiles[p:p+1])[0]))
+	}
+	return v
+}
+
+// eventTypes names all event types in the order compared.
+var eventTypes = append([]stats.Event{
+	{Type: stats.TOTAL, Name: "total"},
+	{Type: stats.READ, Name: "read"},
+	{Type: stats.WRITE, Name: "write"},
+	{Type: stats.COMMIT, Name: "commit"},
+}, stats.Events...)
+
+// trxNames returns total first, then all trx names in A and B sorted.
+func trxNames(a, b intervalStats) []string {
+	seen := map[string]bool{total: true}
+	names := []string{}
+	for _, in := range []intervalStats{a, b} {
+		for trxName := range in.trx {
+			if !seen[trxName] {
+				seen[trxName] = true
+				names = append(names, trxName)
+			}
+		}
+	}
+	sort.Strings(names)
+	return append([]string{total}, names...)
+}
+
+func rate(n uint64, seconds float64) float64 {
+	if seconds == 0 {
+		return 0
+	}
+	return float64(n) / seconds
+}
+
+// pName returns the percentile name like stats.ParsePercentiles: P50, P999.
+func pName(p float64) string {
+	return "P" + strings.ReplaceAll(fmt.Sprintf("%g", p), ".", "")
+}
+
+// welch returns the two-tailed p-value of Welch's t-test for the means of a
+// and b. It returns false if either has fewer than 2 values. If both have
+// zero variance, p is 1 if the means are equal, else 0.
+func welch(a, b []float64) (float64, bool) {
+	if len(a) < 2 || len(b) < 2 {
+		return 0, false
+	}
+	ma, va := meanVar(a)
+	mb, vb := meanVar(b)
+	na, nb := float64(len(a)), float64(len(b))
+	sa, sb := va/na, vb/nb
+	if sa+sb == 0 {
+		if ma == mb {
+			return 1, true
+		}
+		return 0, true
+	}
+	t := (ma - mb) / math.Sqrt(sa+sb)
+	df := (sa + sb) * (sa + sb) / (sa*sa/(na-1) + sb*sb/(nb-1))
+	return betaInc(df/2, 0.5, df/(df+t*t)), true
+}
+
+// meanVar returns the mean and sample variance.
+func meanVar(v []float64) (mean, variance float64) {
+	for _, x := range v {
+		mean += x
+	}
+	mean /= float64(len(v))
+	for _, x := range v {
+		variance += (x - mean) * (x - mean)
+	}
+	variance /= float64(len(v) - 1)
+	return
+}
+
+// betaInc returns the regularized incomplete beta function I_x(a, b), which is
+// the two-tailed p-value of Student's t-distribution for x = df/(df+t^2),
+// a = df/2, b = 1/2. It uses the continued fraction from Numerical Recipes.
+func betaInc(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(a, b, x) / a
+	}
+	return 1 - front*betaCF(b, a, 1-x)/b
+}
+
+func betaCF(a, b, x float64) float64 {
+	const maxIter = 200
+	const eps = 3e-14
+	const fpmin = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < fpmin {
+		d = fpmin
+	}
+	d = 1 / d
+	f := d
+	for m := 1; m <= maxIter; m++ {
+		fm := float64(m)
+		// Even step
+		num := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 + num*d
+		if math.Abs(d) < fpmin {
+			d = fpmin
+		}
+		c = 1 + num/c
+		if math.Abs(c) < fpmin {
+			c = fpmin
+		}
+		d = 1 / d
+		f *= d * c
+		// Odd step
+		num = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 + num*d
+		if math.Abs(d) < fpmin {
+			d = fpmin
+		}
+		c = 1 + num/c
+		if math.Abs(c) < fpmin {
+			c = fpmin
+		}
+		d = 1 / d
+		del := d * c
+		f *= del
+		if math.Abs(del-1) < eps {
+			break
+		}
+	}
+	return f
+}
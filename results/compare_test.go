@@ -0,0 +1,108 @@
+// Copyright 2024 Block, Inc.
+
+package results
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/square/finch/stats"
+)
+
+func TestWelch(t *testing.T) {
+	// t = -1, df = 8: two-tailed p = 0.3466
+	p, ok := welch([]float64{1, 2, 3, 4, 5}, []float64{2, 3, 4, 5, 6})
+	if !ok {
+		t.Fatal("not ok, expected ok")
+	}
+	if math.Abs(p-0.3466) > 0.0001 {
+		t.Errorf("p = %f, expected 0.3466", p)
+	}
+
+	p, _ = welch([]float64{10, 11, 10, 11}, []float64{20, 21, 20, 21})
+	if p >= 0.001 {
+		t.Errorf("p = %f, expected < 0.001", p)
+	}
+
+	if _, ok := welch([]float64{1}, []float64{1, 2}); ok {
+		t.Error("ok with 1 value, expected not ok")
+	}
+}
+
+// writeRun writes a results bundle with one stage, "01-s1", with one interval
+// per QPS value. Each interval is 1s and each query takes d microseconds.
+func writeRun(t *testing.T, dir string, qps []int, d int64) {
+	b, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "01-s1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r, err := stats.NewJSON(map[string]string{"file": filepath.Join(dir, "01-s1", "stats.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range qps {
+		s := stats.NewStats()
+		for j := 0; j < n; j++ {
+			s.Record(stats.READ, d)
+		}
+		in := stats.NewInstance("local")
+		in.Interval, in.Seconds, in.Clients = uint(i+1), 1.0, 1
+		in.Total = s
+		in.Trx["t1"] = s
+		r.Report([]stats.Instance{in})
+	}
+	r.Stop()
+	b.run.Stages = []string{"01-s1"}
+	if err := b.Done(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompareDirs(t *testing.T) {
+	tmpdir, err := os.MkdirTemp("", "finch-compare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	dirA := filepath.Join(tmpdir, "a")
+	dirB := filepath.Join(tmpdir, "b")
+	writeRun(t, dirA, []int{100, 101, 100, 101}, 100)
+	writeRun(t, dirB, []int{200, 201, 200, 201}, 100)
+
+	var buf bytes.Buffer
+	if err := CompareDirs(&buf, dirA, dirB); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "# s1 (A: 4 intervals, B: 4 intervals)") {
+		t.Errorf("stage not compared:\n%s", out)
+	}
+
+	// QPS doubled: significant. Response time is the same: not significant.
+	var qps, p999 string
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Split(line, "|")
+		if len(f) < 8 || strings.TrimSpace(f[0]) != "t1" || strings.TrimSpace(f[1]) != "read" {
+			continue
+		}
+		switch strings.TrimSpace(f[2]) {
+		case "QPS":
+			qps = line
+		case "P999":
+			p999 = line
+		}
+	}
+	if !strings.Contains(qps, "+99.5%") || !strings.HasSuffix(strings.TrimSpace(qps), "*|") {
+		t.Errorf("t1 read QPS not +99.5%% and significant: %s\n%s", qps, out)
+	}
+	if !strings.Contains(p999, "+0.0%") || strings.Contains(p999, "*") {
+		t.Errorf("t1 read P999 not +0.0%% and not significant: %s\n%s", p999, out)
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright 2024 Block, Inc.
+
+// Package results writes a run result bundle (--results DIR): a self-contained
+// directory with everything needed to know what produced the stats.
+package results
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/square/finch"
+	"github.com/square/finch/config"
+	"github.com/square/finch/dbconn"
+	"github.com/square/finch/trx"
+)
+
+// Variables are the SHOW GLOBAL VARIABLES saved for each stage: settings that
+// commonly affect benchmark results.
+var Variables = []string{
+	"binlog_format",
+	"binlog_row_image",
+	"character_set_server",
+	"innodb_adaptive_hash_index",
+	"innodb_buffer_pool_instances",
+	"innodb_buffer_pool_size",
+	"innodb_change_buffering",
+	"innodb_doublewrite",
+	"innodb_flush_log_at_trx_commit",
+	"innodb_flush_method",
+	"innodb_io_capacity",
+	"innodb_io_capacity_max",
+	"innodb_log_buffer_size",
+	"innodb_log_file_size",
+	"innodb_read_io_threads",
+	"innodb_redo_log_capacity",
+	"innodb_thread_concurrency",
+	"innodb_write_io_threads",
+	"log_bin",
+	"max_connections",
+	"performance_schema",
+	"sync_binlog",
+	"table_open_cache",
+	"thread_cache_size",
+	"transaction_isolation",
+	"version_comment",
+}
+
+// Run is finch.json: the Finch run.
+type Run struct {
+	Version      string            `json:"version"`
+	SystemParams map[string]string `json:"system_params"`
+	Args         []string          `json:"args"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	Stages       []string          `json:"stages"` // stage dirs in run order
+}
+
+// MySQL is mysql.json in each stage dir: the MySQL server when the stage started.
+// Session is workload[].session for each client group in stage.workload order
+// (null if not set), or omitted if no client group sets session variables.
+type MySQL struct {
+	Version   string            `json:"version"`
+	Variables map[string]string `json:"variables"`
+	Session   []config.Session  `json:"session,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// Bundle writes a result bundle in a directory:
+//
+//	finch.json              Run
+//	NN-stage-name/          for each stage in run order
+//	  stage.yaml            final stage config, passwords redacted
+//	  trx/                  trx files, includes expanded, like 01-name.sql
+//	  mysql.json            MySQL
+//	  stats.jsonl           per-interval stats (stats.JSON reporter)
+//
+// Stage is called before each stage runs, and Done after all stages.
+type Bundle struct {
+	dir string
+	run Run
+}
+
+// New makes the bundle dir and writes finch.json. The dir must not already
+// contain a bundle.
+func New(dir string, args []string) (*Bundle, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if config.FileExists(filepath.Join(dir, "finch.json")) {
+		return nil, fmt.Errorf("%s already contains results (finch.json); specify a new or empty directory", dir)
+	}
+	b := &Bundle{
+		dir: dir,
+		run: Run{
+			Version:      finch.VERSION,
+			SystemParams: finch.SystemParams,
+			Args:         redactArgs(args),
+			Start:        time.Now(),
+			Stages:       []string{},
+		},
+	}
+	if err := b.writeRun(); err != nil {
+		return nil, err
+	}
+	log.Printf("Results: %s\n", dir)
+	return b, nil
+}
+
+// Stage writes the stage files and sets the stage to report stats to the bundle.
+// Errors reading MySQL are saved in mysql.json instead of returned because they're
+// not fatal: the stage will report connection errors.
+func (b *Bundle) Stage(ctx context.Context, cfg *config.Stage) error {
+	dir, err := b.stageFiles(cfg)
+	if err != nil {
+		return err
+	}
+
+	m := mysqlInfo(ctx, cfg.MySQL)
+	m.Session = sessions(cfg)
+	if err := writeJSON(filepath.Join(dir, "mysql.json"), m); err != nil {
+		return err
+	}
+
+	if !config.True(cfg.Stats.Disable) {
+		if cfg.Stats.Report == nil {
+			cfg.Stats.Report = map[string]map[string]string{}
+		}
+		cfg.Stats.Report["json"] = map[string]string{"file": filepath.Join(dir, "stats.jsonl")}
+	}
+
+	b.run.Stages = append(b.run.Stages, filepath.Base(dir))
+	return b.writeRun()
+}
+
+// Done writes the end time and error, if any, to finch.json.
+func (b *Bundle) Done(err error) error {
+	b.run.End = time.Now()
+	if err != nil {
+		b.run.Error = err.Error()
+	}
+	return b.writeRun()
+}
+
+// stageFiles makes the stage dir, writes stage.yaml, and copies trx files.
+func (b *Bundle) stageFiles(cfg *config.Stage) (string, error) {
+	dir := filepath.Join(b.dir, stageDir(cfg))
+	if err := os.MkdirAll(filepath.Join(dir, "trx"), 0755); err != nil {
+		return "", err
+	}
+
+	bytes, err := yaml.Marshal(map[string]config.Stage{"stage": Redact(*cfg)})
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "stage.yaml"), bytes, 0644); err != nil {
+		return "", err
+	}
+
+	// Trx files with includes expanded (like compute.API for remote instances)
+	// because included files aren't saved. The trx number prefix prevents trx
+	// files with the same name in different dirs from overwriting each other.
+	for i, t := range cfg.Trx {
+		src := t.File
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(filepath.Dir(cfg.File), src) // relative to stage file
+		}
+		bytes, err := trx.Expand(src, cfg.Params)
+		if err != nil {
+			return "", err
+		}
+		name := fmt.Sprintf("%02d-%s", i+1, filepath.Base(t.File))
+		if err := os.WriteFile(filepath.Join(dir, "trx", name), bytes, 0644); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+func (b *Bundle) writeRun() error {
+	return writeJSON(filepath.Join(b.dir, "finch.json"), b.run)
+}
+
+var reNotFileSafe = regexp.MustCompile(`[^A-Za-z0-9._=-]+`)
+
+// stageDir returns the stage dir name: stage number and name, like "01-read-only".
+func stageDir(cfg *config.Stage) string {
+	return fmt.Sprintf("%02d-%s", cfg.N, strings.Trim(reNotFileSafe.ReplaceAllString(cfg.Name, "_"), "_"))
+}
+
+// reSecretName matches param and environment variable names that probably
+// have secret values, like "password" or "API_TOKEN".
+var reSecretName = regexp.MustCompile(`(?i)pass|secret|token|credential|api.?key`)
+
+// Secrets in command line args and SQL, which are redacted in hooks even if the
+// value isn't a known secret: -pPASS and --password=PASS (mysql CLI), user:pass@
+// in URLs and DSNs, and IDENTIFIED BY 'PASS'.
+var (
+	reSecretFlag   = regexp.MustCompile(`(\s-p|--password[= ])\S+`)
+	reSecretURL    = regexp.MustCompile(`([\w.-]+:)[^@\s/]+@`)
+	reIdentifiedBy = regexp.MustCompile(`(?i)(IDENTIFIED\s+BY\s+)'[^']*'`)
+)
+
+// Redact returns a copy of the stage config with secrets redacted: MySQL passwords,
+// params with secret names (see reSecretName), and secrets in hooks. Known secret
+// values (MySQL passwords, secret params, and environment variables with secret
+// names) are also redacted where they appear in other params and hooks because
+// config values are interpolated. Other values are not redacted.
+func Redact(cfg config.Stage) config.Stage {
+	secrets := []string{}
+	addSecret := func(s string) {
+		if len(s) >= 4 { // short values would redact too much
+			secrets = append(secrets, s)
+		}
+	}
+	for _, env := range os.Environ() {
+		if kv := strings.SplitN(env, "=", 2); len(kv) == 2 && reSecretName.MatchString(kv[0]) {
+			addSecret(kv[1])
+		}
+	}
+
+	addSecret(cfg.MySQL.Password)
+	cfg.MySQL = redactMySQL(cfg.MySQL)
+	if cfg.Lag != nil {
+		lag := *cfg.Lag
+		addSecret(lag.Replica.Password)
+		lag.Replica = redactMySQL(lag.Replica)
+		cfg.Lag = &lag
+	}
+	workload := make([]config.ClientGroup, len(cfg.Workload))
+	for i, cg := range cfg.Workload {
+		if cg.MySQL != nil {
+			addSecret(cg.MySQL.Password)
+			m := redactMySQL(*cg.MySQL)
+			cg.MySQL = &m
+		}
+		workload[i] = cg
+	}
+	cfg.Workload = workload
+
+	if cfg.Params != nil {
+		params := make(map[string]string, len(cfg.Params))
+		for k, v := range cfg.Params {
+			if reSecretName.MatchString(k) {
+				addSecret(v)
+				v = "..."
+			}
+			params[k] = v
+		}
+		for k, v := range params {
+			params[k] = redactSecrets(v, secrets)
+		}
+		cfg.Params = params
+	}
+
+	if cfg.Hooks != nil {
+		hooks := config.Hooks{}
+		for _, h := range []struct{ dst, src *[]config.Hook }{
+			{&hooks.BeforeStage, &cfg.Hooks.BeforeStage},
+			{&hooks.BeforeRun, &cfg.Hooks.BeforeRun},
+			{&hooks.OnInterval, &cfg.Hooks.OnInterval},
+			{&hooks.AfterStage, &cfg.Hooks.AfterStage},
+		} {
+			for _, hook := range *h.src {
+				hook.SQL = redactSecrets(hook.SQL, secrets)
+				hook.Exec = redactSecrets(hook.Exec, secrets)
+				*h.dst = append(*h.dst, hook)
+			}
+		}
+		cfg.Hooks = &hooks
+	}
+	return cfg
+}
+
+// redactSecrets returns s with the secret values and secret patterns redacted.
+func redactSecrets(s string, secrets []string) string {
+	if s == "" {
+		return s
+	}
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, "...")
+	}
+	s = reSecretFlag.ReplaceAllString(s, "${1}...")
+	s = reSecretURL.ReplaceAllString(s, "${1}...@")
+	return reIdentifiedBy.ReplaceAllString(s, "${1}'...'")
+}
+
+func redactMySQL(m config.MySQL) config.MySQL {
+	if m.Password != "" {
+		m.Password = "..."
+	}
+	if m.DSN != "" {
+		m.DSN = dbconn.RedactedDSN(m.DSN)
+	}
+	return m
+}
+
+// redactArgs returns a copy of the command line args with the --dsn password
+// redacted.
+func redactArgs(args []string) []string {
+	r := make([]string, len(args))
+	copy(r, args)
+	for i := range r {
+		switch {
+		case strings.HasPrefix(r[i], "--dsn="):
+			r[i] = "--dsn=" + dbconn.RedactedDSN(strings.TrimPrefix(r[i], "--dsn="))
+		case r[i] == "--dsn" && i+1 < len(r):
+			r[i+1] = dbconn.RedactedDSN(r[i+1])
+		}
+	}
+	return r
+}
+
+// mysqlInfo returns the MySQL version and Variables.
+func mysqlInfo(ctx context.Context, cfg config.MySQL) MySQL {
+	m := MySQL{Variables: map[string]string{}}
+	db, _, err := dbconn.MakeWith(cfg)
+	if err != nil {
+		m.Error = err.Error()
+		return m
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := db.QueryRowContext(ctx, "SELECT @@version").Scan(&m.Version); err != nil {
+		m.Error = err.Error()
+		return m
+	}
+	if err := variables(ctx, db, m.Variables); err != nil {
+		m.Error = err.Error()
+	}
+	return m
+}
+
+// sessions returns workload[].session for each client group, or nil if none
+// set session variables.
+func sessions(cfg *config.Stage) []config.Session {
+	var s []config.Session
+	for i := range cfg.Workload {
+		if len(cfg.Workload[i].Session) == 0 {
+			continue
+		}
+		if s == nil {
+			s = make([]config.Session, len(cfg.Workload))
+		}
+		s[i] = cfg.Workload[i].Session
+	}
+	return s
+}
+
+func variables(ctx context.Context, db *sql.DB, vars map[string]string) error {
+	q := "SHOW GLOBAL VARIABLES WHERE Variable_name IN ('" + strings.Join(Variables, "','") + "')"
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var name, val string
+	for rows.Next() {
+		if err := rows.Scan(&name, &val); err != nil {
+			return err
+		}
+		vars[name] = val
+	}
+	return rows.Err()
+}
+
+func writeJSON(file string, v interface{}) error {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(bytes, '\n'), 0644)
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 Block, Inc.
+
+package stage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/square/finch/config"
+	"github.com/square/finch/stats"
+)
+
+// eventRun is one run of a stage event (config.stage.events).
+type eventRun struct {
+	event    int           // index in config.stage.events
+	start    time.Duration // offset from stage start
+	duration time.Duration
+	err      error
+}
+
+// startEvents starts a goroutine for each stage event that runs the event trx
+// on its dedicated client (Allocator.Events) at the configured offset or interval
+// from now (stage start). An event does not overlap itself: if it's still running
+// at the next interval, that interval is skipped. Events run with ctxFinch, not
+// the stage runtime, so the returned func must be called when the workload is done
+// to stop the events: events not yet run are skipped, and running events are
+// waited for.
+func (s *Stage) startEvents(ctxFinch context.Context) func() {
+	if len(s.events) == 0 {
+		return func() {}
+	}
+	start := time.Now()
+	stop := make(chan struct{})
+	running := int32(0)
+	wg := &sync.WaitGroup{}
+	for i := range s.events {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.runEvent(ctxFinch, stop, start, i, &running)
+		}(i)
+	}
+	return func() {
+		close(stop)
+		if n := atomic.LoadInt32(&running); n > 0 {
+			log.Printf("[%s] Waiting for %d events to finish", s.cfg.Name, n)
+		}
+		wg.Wait()
+	}
+}
+
+func (s *Stage) runEvent(ctx context.Context, stop chan struct{}, start time.Time, i int, running *int32) {
+	e := s.cfg.Events[i]
+	var tick <-chan time.Time
+	if e.At != "" {
+		d, _ := time.ParseDuration(e.At) // already validated
+		timer := time.NewTimer(time.Until(start.Add(d)))
+		defer timer.Stop()
+		tick = timer.C
+	} else {
+		d, _ := time.ParseDuration(e.Every) // already validated
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	c := s.events[i]
+	for {
+		select {
+		case <-tick:
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+		atomic.AddInt32(running, 1)
+		log.Printf("[%s] Event %d: running %s", s.cfg.Name, i+1, e.Trx)
+		c.Init()
+		t0 := time.Now()
+		c.Run(ctx)
+		<-c.DoneChan
+		r := eventRun{
+			event:    i,
+			start:    t0.Sub(start),
+			duration: time.Since(t0),
+			err:      c.Error.Err,
+		}
+		atomic.AddInt32(running, -1)
+		s.eventMux.Lock()
+		s.eventRuns = append(s.eventRuns, r)
+		if s.eventStats != nil {
+			// Client is done, so swap and combine its stats for this run
+			for _, t := range c.Stats {
+				if t != nil {
+					s.eventStats[i].Combine(t.Swap())
+				}
+			}
+		}
+		s.eventMux.Unlock()
+		if e.At != "" {
+			return // run once
+		}
+	}
+}
+
+// reportEvents prints the event timeline, if any events ran.
+func (s *Stage) reportEvents() {
+	if len(s.eventRuns) == 0 {
+		return
+	}
+	log.Printf("[%s] Events:\n", s.cfg.Name)
+	eventTimeline(os.Stdout, s.cfg.Events, s.eventRuns, s.eventStats)
+}
+
+// eventPercentiles are the response time percentiles in the event stats.
+var eventPercentiles = []float64{50, 99, 99.9}
+
+// eventTimeline prints all event runs in time order, then the number of runs,
+// errors, and total and max duration per event. If eventStats is not nil (stats
+// enabled), it then prints the statement stats of each event, all runs combined,
+// separate from the workload stats: query count and response time (μs).
+func eventTimeline(w io.Writer, events []config.Event, runs []eventRun, eventStats []*stats.Stats) {
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].start < runs[j].start })
+	tw := tabwriter.NewWriter(w, 1, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "start\tevent\ttrx\tduration\t")
+	for _, r := range runs {
+		errStr := ""
+		if r.err != nil {
+			errStr = r.err.Error()
+		}
+		fmt.Fprintf(tw, "%.3fs\t%d\t%s\t%.3fs\t%s\n", r.start.Seconds(), r.event+1, events[r.event].Trx, r.duration.Seconds(), errStr)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 1, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "event\ttrx\truns\terrors\tduration\tmax\t")
+	for i, e := range events {
+		var n, errs int
+		var sum, max time.Duration
+		for _, r := range runs {
+			if r.event != i {
+				continue
+			}
+			n++
+			if r.err != nil {
+				errs++
+			}
+			sum += r.duration
+			if r.duration > max {
+				max = r.duration
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%.3fs\t%.3fs\t\n", i+1, e.Trx, n, errs, sum.Seconds(), max.Seconds())
+	}
+	tw.Flush()
+
+	if eventStats == nil {
+		return
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 1, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "event\ttrx\tqueries\terrors\tmin\tP50\tP99\tP999\tmax\t")
+	for i, e := range events {
+		s := eventStats[i]
+		errors, timeouts := s.ErrorCount()
+		p := []uint64{0, 0, 0}
+		if s.N[stats.TOTAL] > 0 {
+			p = s.Percentiles(stats.TOTAL, eventPercentiles)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t\n", i+1, e.Trx, s.N[stats.TOTAL], errors+timeouts,
+			s.Min[stats.TOTAL], p[0], p[1], p[2], s.Max[stats.TOTAL])
+	}
+	tw.Flush()
+}
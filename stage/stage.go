@@ -4,9 +4,15 @@ package stage
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"runtime/pprof"
+	"sort"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/square/finch"
@@ -14,7 +20,9 @@ import (
 	"github.com/square/finch/config"
 	"github.com/square/finch/data"
 	"github.com/square/finch/dbconn"
+	"github.com/square/finch/lag"
 	"github.com/square/finch/limit"
+	"github.com/square/finch/metrics"
 	"github.com/square/finch/stats"
 	"github.com/square/finch/trx"
 	"github.com/square/finch/workload"
@@ -31,6 +39,14 @@ type Stage struct {
 	// --
 	doneChan   chan *client.Client      // <-Client.Run()
 	execGroups [][]workload.ClientGroup // [n][Client]
+	lag        *lag.Probe               // config.stage.lag
+	metrics    *metrics.Server          // config.stage.stats.server-metrics
+	digests    *metrics.Digests         // config.stage.stats.digests
+	locks      *metrics.Locks           // config.stage.stats.lock-diagnostics
+	events     []*client.Client         // config.stage.events
+	eventRuns  []eventRun
+	eventStats []*stats.Stats // per event, all runs, if stats enabled
+	eventMux   *sync.Mutex
 }
 
 func New(cfg config.Stage, gds *data.Scope, stats *stats.Collector) *Stage {
@@ -40,27 +56,75 @@ func New(cfg config.Stage, gds *data.Scope, stats *stats.Collector) *Stage {
 		stats: stats,
 		// --
 		doneChan: make(chan *client.Client, 1),
+		eventMux: &sync.Mutex{},
 	}
 }
 
-func (s *Stage) Prepare(ctxFinch context.Context) error {
+func (s *Stage) Prepare(ctxFinch context.Context) (err error) {
 	if len(s.cfg.Trx) == 0 {
 		panic("Stage.Prepare called with zero trx")
 	}
 
+	// Stats samplers (lag, server metrics, digests, lock diagnostics) have their
+	// own MySQL connections, which Run closes. If Prepare fails, Run isn't called,
+	// so close them.
+	defer func() {
+		if err != nil {
+			s.stopSamplers()
+		}
+	}()
+
 	// Test connection to MySQL
 	dbconn.SetConfig(s.cfg.MySQL)
 	db, dsnRedacted, err := dbconn.Make()
 	if err != nil {
 		return err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := db.PingContext(ctx); err != nil {
-		return fmt.Errorf("test connection to MySQL failed: %s: %s", dsnRedacted, err)
+	if err := ping(db, dsnRedacted); err != nil {
+		return err
+	}
+
+	// Test connections to client group MySQL targets, if any
+	for i := range s.cfg.Workload {
+		if s.cfg.Workload[i].MySQL == nil {
+			continue
+		}
+		db, dsnRedacted, err := dbconn.MakeWith(*s.cfg.Workload[i].MySQL)
+		if err != nil {
+			return fmt.Errorf("workload[%d].mysql: %s", i, err)
+		}
+		if err := ping(db, dsnRedacted); err != nil {
+			return fmt.Errorf("workload[%d].mysql: %s", i, err)
+		}
+	}
+
+	// Replication lag probe (config.stage.lag), if any. It's a stats sampler,
+	// so it's useless without stats.
+	if s.cfg.Lag != nil {
+		if s.stats == nil {
+			log.Printf("[%s] WARNING: stats disabled, ignoring lag", s.cfg.Name)
+		} else if err := s.prepareLag(ctxFinch); err != nil {
+			return fmt.Errorf("lag: %s", err)
+		}
+	}
+
+	// Server metrics (config.stage.stats.server-metrics), if any. It's a stats
+	// sampler, too.
+	if s.cfg.Stats.ServerMetrics != nil {
+		if s.stats == nil {
+			log.Printf("[%s] WARNING: stats disabled, ignoring stats.server-metrics", s.cfg.Name)
+		} else {
+			db, _, err := dbconn.Make()
+			if err != nil {
+				return err
+			}
+			s.metrics = metrics.NewServer(*s.cfg.Stats.ServerMetrics, db)
+			if err := s.metrics.Prepare(ctxFinch); err != nil {
+				return fmt.Errorf("stats.server-metrics: %s", err) // closed by stopSamplers
+			}
+			s.stats.AddSampler(s.metrics)
+		}
 	}
-	db.Close() // test conn
-	log.Printf("Connected to %s", dsnRedacted)
 
 	// Load and validate all config.stage.trx files. This makes and validates all
 	// data generators, too. Being valid means only that the Finch config/setup is
@@ -71,6 +135,37 @@ func (s *Stage) Prepare(ctxFinch context.Context) error {
 	if err != nil {
 		return err
 	}
+	// Statement digest report (config.stage.stats.digests), if any
+	if s.cfg.Stats.Digests != nil {
+		if s.stats == nil {
+			log.Printf("[%s] WARNING: stats disabled, ignoring stats.digests", s.cfg.Name)
+		} else {
+			db, _, err := dbconn.Make()
+			if err != nil {
+				return err
+			}
+			s.digests = metrics.NewDigests(*s.cfg.Stats.Digests, db, trxSet)
+			if err := s.digests.Prepare(ctxFinch); err != nil {
+				return fmt.Errorf("stats.digests: %s", err) // closed by stopSamplers
+			}
+		}
+	}
+
+	// Lock diagnostics (config.stage.stats.lock-diagnostics), if any
+	if s.cfg.Stats.LockDiagnostics != nil {
+		if s.stats == nil {
+			log.Printf("[%s] WARNING: stats disabled, ignoring stats.lock-diagnostics", s.cfg.Name)
+		} else {
+			db, _, err := dbconn.Make()
+			if err != nil {
+				return err
+			}
+			s.locks = metrics.NewLocks(*s.cfg.Stats.LockDiagnostics, db, trxSet)
+			if err := s.locks.Prepare(ctxFinch); err != nil {
+				return fmt.Errorf("stats.lock-diagnostics: %s", err) // closed by stopSamplers
+			}
+		}
+	}
 
 	// Allocate the workload (config.stage.workload): execution groups, client groups,
 	// clients, and trx assigned to clients. This is done in two steps. First, Groups
@@ -85,7 +180,10 @@ func (s *Stage) Prepare(ctxFinch context.Context) error {
 		Workload:  s.cfg.Workload,
 		StageQPS:  limit.NewRate(finch.Uint(s.cfg.QPS)), // nil if config.stage.qps == 0
 		StageTPS:  limit.NewRate(finch.Uint(s.cfg.TPS)), // nil if config.stage.tps == 0
+		Errors:    s.cfg.Errors,
 		DoneChan:  s.doneChan,
+		EventTrx:  s.cfg.EventTrx(),
+		Bytes:     s.cfg.Stats.Throughput(),
 	}
 	groups, err := a.Groups()
 	if err != nil {
@@ -95,6 +193,23 @@ func (s *Stage) Prepare(ctxFinch context.Context) error {
 	if err != nil {
 		return err
 	}
+	if s.stats != nil && a.ConcurrentDDL() {
+		s.stats.MarkDDL() // DML stats before, during, and after DDL
+	}
+
+	// Stage events (config.stage.events), if any, on dedicated clients
+	if len(s.cfg.Events) > 0 {
+		s.events, err = a.Events(s.cfg.Events, s.stats != nil)
+		if err != nil {
+			return fmt.Errorf("events: %s", err)
+		}
+		if s.stats != nil {
+			s.eventStats = make([]*stats.Stats, len(s.events))
+			for i := range s.eventStats {
+				s.eventStats[i] = stats.NewStats()
+			}
+		}
+	}
 
 	// Initialize all clients in all exec groups, and register their stats with
 	// the Collector
@@ -105,6 +220,12 @@ func (s *Stage) Prepare(ctxFinch context.Context) error {
 				if err := c.Init(); err != nil {
 					return err
 				}
+				if s.digests != nil {
+					c.Times = make([]client.StatementTime, len(c.Statements))
+				}
+				if s.locks != nil {
+					c.LockErrors = s.locks.Errors()
+				}
 				if s.stats != nil {
 					s.stats.Watch(c.Stats)
 				}
@@ -137,14 +258,28 @@ func (s *Stage) Run(ctxFinch context.Context) {
 		log.Printf("[%s] Running (no runtime limit)", s.cfg.Name)
 	}
 
+	if s.metrics != nil {
+		s.metrics.Start(ctxFinch) // initial values for deltas
+	}
+	if s.digests != nil {
+		s.digests.Start(ctxFinch) // truncate or initial values for deltas
+	}
+	if s.locks != nil {
+		s.locks.Start(ctxFinch)
+	}
 	if s.stats != nil {
 		s.stats.Start()
 	}
+	if s.lag != nil {
+		s.lag.Start(ctxFinch)
+	}
 
 	if finch.CPUProfile != nil {
 		pprof.StartCPUProfile(finch.CPUProfile)
 	}
 
+	stopEvents := s.startEvents(ctxFinch)
+
 	for egNo := range s.execGroups { // ------------------------------------- execution groups
 		if ctxFinch.Err() != nil {
 			break
@@ -223,6 +358,8 @@ func (s *Stage) Run(ctxFinch context.Context) {
 		}
 	}
 
+	stopEvents()
+
 	if finch.CPUProfile != nil {
 		pprof.StopCPUProfile()
 	}
@@ -232,4 +369,175 @@ func (s *Stage) Run(ctxFinch context.Context) {
 			log.Printf("\n[%s] Timeout waiting for final statistics, reported values are incomplete", s.cfg.Name)
 		}
 	}
+	if s.lag != nil {
+		s.lag.Stop() // after final stats because it's sampled
+	}
+	if s.metrics != nil {
+		s.metrics.Stop()
+	}
+	if s.digests != nil {
+		s.reportDigests()
+	}
+	if s.locks != nil {
+		s.locks.Stop()
+		log.Printf("[%s] Lock diagnostics:\n", s.cfg.Name)
+		s.locks.Report(os.Stdout)
+	}
+	s.reportReconnects()
+	s.reportEvents()
+}
+
+// reportReconnects prints the reconnect timeline of all clients, if any client
+// reconnected.
+func (s *Stage) reportReconnects() {
+	clients := []*client.Client{}
+	for egNo := range s.execGroups {
+		for cgNo := range s.execGroups[egNo] {
+			clients = append(clients, s.execGroups[egNo][cgNo].Clients...)
+		}
+	}
+	if !reconnected(clients) {
+		return
+	}
+	log.Printf("[%s] Reconnects:\n", s.cfg.Name)
+	reconnectTimeline(os.Stdout, clients)
+}
+
+func reconnected(clients []*client.Client) bool {
+	for _, c := range clients {
+		if c.ReconnectTotal.N > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// reconnectTimeline prints all reconnects in time order, then the number of
+// reconnects and total downtime per client that reconnected. The timeline has
+// up to client.MaxReconnects per client; the per-client totals are complete.
+func reconnectTimeline(w io.Writer, clients []*client.Client) {
+	type reconnect struct {
+		clientId string
+		client.Reconnect
+	}
+	all := []reconnect{}
+	var notShown uint
+	for _, c := range clients {
+		for _, r := range c.Reconnects {
+			all = append(all, reconnect{c.RunLevel.ClientId(), r})
+		}
+		notShown += c.ReconnectTotal.N - uint(len(c.Reconnects))
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Time.Before(all[j].Time) })
+
+	tw := tabwriter.NewWriter(w, 1, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "time\tclient\terror\tdowntime\t")
+	for _, r := range all {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.3fs\t%s\n", r.Time.Format("15:04:05.000"), r.clientId, r.Code, r.Downtime.Seconds(), r.Error)
+	}
+	tw.Flush()
+	if notShown > 0 {
+		fmt.Fprintf(w, "(%d more not shown: max %d per client)\n", notShown, client.MaxReconnects)
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 1, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "client\treconnects\tdowntime\tmax\t")
+	for _, c := range clients {
+		t := c.ReconnectTotal
+		if t.N == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.3fs\t%.3fs\t\n", c.RunLevel.ClientId(), t.N, t.Downtime.Seconds(), t.Max.Seconds())
+	}
+	tw.Flush()
+}
+
+// reportDigests prints the statement digest report with client-side response
+// times summed from all clients.
+func (s *Stage) reportDigests() {
+	defer s.digests.Stop()
+	times := map[*trx.Statement]client.StatementTime{}
+	for egNo := range s.execGroups {
+		for cgNo := range s.execGroups[egNo] {
+			for _, c := range s.execGroups[egNo][cgNo].Clients {
+				for i, t := range c.Times {
+					sum := times[c.Statements[i]]
+					sum.N += t.N
+					sum.Sum += t.Sum
+					times[c.Statements[i]] = sum
+				}
+			}
+		}
+	}
+	// Not ctxFinch: report even if Finch was terminated (CTRL-C)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	log.Printf("[%s] Statement digests (response time in microseconds):\n", s.cfg.Name)
+	if err := s.digests.Report(ctx, os.Stdout, times); err != nil {
+		log.Printf("[%s] Error reporting statement digests: %s", s.cfg.Name, err)
+	}
+}
+
+// stopSamplers stops stats samplers made by Prepare, if any, which closes their
+// MySQL connections. It's called only if Prepare fails; else, Run stops them.
+func (s *Stage) stopSamplers() {
+	if s.lag != nil {
+		s.lag.Stop()
+		s.lag = nil
+	}
+	if s.metrics != nil {
+		s.metrics.Stop()
+		s.metrics = nil
+	}
+	if s.digests != nil {
+		s.digests.Stop()
+		s.digests = nil
+	}
+	if s.locks != nil {
+		s.locks.Stop()
+		s.locks = nil
+	}
+}
+
+// prepareLag makes the lag probe and registers it with the stats collector.
+func (s *Stage) prepareLag(ctx context.Context) error {
+	source, _, err := dbconn.Make()
+	if err != nil {
+		return err
+	}
+	replica, dsnRedacted, err := dbconn.MakeWith(s.cfg.Lag.Replica)
+	if err != nil {
+		source.Close()
+		return fmt.Errorf("replica: %s", err)
+	}
+	ctxPing, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := replica.PingContext(ctxPing); err != nil {
+		source.Close()
+		replica.Close()
+		return fmt.Errorf("test connection to replica failed: %s: %s", dsnRedacted, err)
+	}
+	log.Printf("Connected to replica %s", dsnRedacted)
+	s.lag = lag.NewProbe(*s.cfg.Lag, source, replica)
+	if err := s.lag.Prepare(ctx); err != nil {
+		source.Close()
+		replica.Close()
+		s.lag = nil
+		return err
+	}
+	s.stats.AddSampler(s.lag)
+	return nil
+}
+
+// ping tests and closes the connection to MySQL.
+func ping(db *sql.DB, dsnRedacted string) error {
+	defer db.Close() // test conn
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("test connection to MySQL failed: %s: %s", dsnRedacted, err)
+	}
+	log.Printf("Connected to %s", dsnRedacted)
+	return nil
 }
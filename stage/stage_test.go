@@ -1,11 +1,17 @@
 package stage
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/square/finch"
+	"github.com/square/finch/client"
 	"github.com/square/finch/config"
 	"github.com/square/finch/data"
+	"github.com/square/finch/stats"
 	"github.com/square/finch/test"
 )
 
@@ -54,3 +60,90 @@ func TestPreapre_NoWorkload(t *testing.T) {
 		t.Fatalf("got %d clients, expected 1", len(s.execGroups[0]))
 	}
 }
+
+func TestReconnectTimeline(t *testing.T) {
+	t0 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c1 := &client.Client{RunLevel: finch.RunLevel{Stage: 1, StageName: "s", ExecGroup: 1, ExecGroupName: "e", ClientGroup: 1, Client: 1}}
+	c2 := &client.Client{RunLevel: finch.RunLevel{Stage: 1, StageName: "s", ExecGroup: 1, ExecGroupName: "e", ClientGroup: 1, Client: 2}}
+	c3 := &client.Client{} // no reconnects
+	c1.Reconnects = []client.Reconnect{
+		{Time: t0.Add(2 * time.Second), Code: 2013, Error: "lost", Downtime: 1500 * time.Millisecond},
+		{Time: t0.Add(10 * time.Second), Code: 0, Error: "bad conn", Downtime: 250 * time.Millisecond},
+	}
+	c2.Reconnects = []client.Reconnect{
+		{Time: t0.Add(3 * time.Second), Code: 2013, Error: "lost", Downtime: 2 * time.Second},
+	}
+	c1.ReconnectTotal = client.ReconnectTotal{N: 2, Downtime: 1750 * time.Millisecond, Max: 1500 * time.Millisecond}
+	c2.ReconnectTotal = client.ReconnectTotal{N: 3, Downtime: 2500 * time.Millisecond, Max: 2 * time.Second} // 2 not in timeline (MaxReconnects)
+	clients := []*client.Client{c1, c2, c3}
+	if !reconnected(clients) {
+		t.Error("reconnected false, expected true")
+	}
+	if reconnected([]*client.Client{c3}) {
+		t.Error("reconnected true, expected false")
+	}
+
+	var buf bytes.Buffer
+	reconnectTimeline(&buf, clients)
+	expect := `time          client            error  downtime  
+03:04:07.000  1(s)/e1(e)/g1/c1  2013   1.500s    lost
+03:04:08.000  1(s)/e1(e)/g1/c2  2013   2.000s    lost
+03:04:15.000  1(s)/e1(e)/g1/c1  0      0.250s    bad conn
+(2 more not shown: max 100 per client)
+
+client            reconnects  downtime  max     
+1(s)/e1(e)/g1/c1  2           1.750s    1.500s  
+1(s)/e1(e)/g1/c2  3           2.500s    2.000s  
+`
+	if buf.String() != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.String(), expect)
+	}
+}
+
+func TestEventTimeline(t *testing.T) {
+	events := []config.Event{
+		{At: "10s", Trx: "add-index.sql"},
+		{Every: "5s", Trx: "purge.sql"},
+	}
+	runs := []eventRun{ // in order done, not started
+		{event: 1, start: 5 * time.Second, duration: 200 * time.Millisecond},
+		{event: 1, start: 10 * time.Second, duration: 300 * time.Millisecond, err: fmt.Errorf("Error 1205: lock wait timeout")},
+		{event: 0, start: 10 * time.Second, duration: 12 * time.Second},
+		{event: 1, start: 15 * time.Second, duration: 100 * time.Millisecond},
+	}
+	var buf bytes.Buffer
+	eventTimeline(&buf, events, runs, nil) // stats disabled
+	expect := `start    event  trx            duration  
+5.000s   2      purge.sql      0.200s    
+10.000s  2      purge.sql      0.300s    Error 1205: lock wait timeout
+10.000s  1      add-index.sql  12.000s   
+15.000s  2      purge.sql      0.100s    
+
+event  trx            runs  errors  duration  max      
+1      add-index.sql  1     0       12.000s   12.000s  
+2      purge.sql      3     1       0.600s    0.300s   
+`
+	if buf.String() != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.String(), expect)
+	}
+
+	// With stats: event statement stats after the timeline and summary.
+	// Client records every statement as TOTAL, too.
+	s1 := stats.NewStats()
+	s1.Record(stats.DDL, 12000000)
+	s1.Record(stats.TOTAL, 12000000)
+	s2 := stats.NewStats()
+	s2.Record(stats.WRITE, 1000)
+	s2.Record(stats.WRITE, 2000)
+	s2.Record(stats.COMMIT, 500)
+	buf.Reset()
+	eventTimeline(&buf, events, runs, []*stats.Stats{s1, s2})
+	expectStats := expect + `
+event  trx            queries  errors  min       P50       P99       P999      max       
+1      add-index.sql  1        0       12000000  11752090  12305949  12885910  12000000  
+2      purge.sql      3        0       500       977       2042      2138      2000      
+`
+	if buf.String() != expectStats {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.String(), expectStats)
+	}
+}
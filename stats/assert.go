@@ -0,0 +1,160 @@
+// Copyright 2024 Block, Inc.
+
+package stats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/square/finch/config"
+)
+
+// Assertion is a stats.assert rule (config.Assert) resolved to a stats value.
+// The metric is a stats column name: an optional event type prefix (like r_
+// or trx_; see Events) and one of:
+//
+//	qps, tps, cps  rate (per second) of the event type
+//	min, max       response time
+//	pN             response time percentile, like p99 or p999 (99.9)
+//	errors         error count, excluding timeouts
+//	timeouts       statement timeout count
+//
+// Without a prefix, the event type is TOTAL, except tps which is COMMIT to match
+// the stdout reporter column TPS. Response times are microseconds, which is why
+// config.ParseAssert converts durations like 5ms to microseconds.
+type Assertion struct {
+	config.Assert
+	eventType byte
+	metric    string  // rate, min, max, pN, errors, timeouts
+	p         float64 // percentile if metric == pN
+}
+
+// AssertResult is the result of one Assertion. Value is the actual value
+// compared to the rule value.
+type AssertResult struct {
+	Rule  string
+	Value float64
+	Pass  bool
+}
+
+var assertPrefix = map[string]byte{
+	"r_": READ,
+	"w_": WRITE,
+	"c_": COMMIT,
+}
+
+func init() {
+	for _, e := range Events {
+		assertPrefix[e.Prefix] = e.Type
+	}
+}
+
+// ParseAssertion parses and resolves a stats.assert rule.
+func ParseAssertion(rule string) (Assertion, error) {
+	ca, err := config.ParseAssert(rule)
+	if err != nil {
+		return Assertion{}, err
+	}
+	a := Assertion{Assert: ca, eventType: TOTAL}
+
+	name := ca.Metric
+	if p := strings.LastIndex(name, "_"); p > 0 {
+		t, ok := assertPrefix[name[0:p+1]]
+		if !ok {
+			return a, fmt.Errorf("invalid metric prefix in rule: %s: %s", rule, name[0:p+1])
+		}
+		a.eventType = t
+		name = name[p+1:]
+	} else if name == "tps" {
+		a.eventType = COMMIT
+	}
+
+	switch name {
+	case "qps", "tps", "cps":
+		a.metric = "rate"
+	case "min", "max":
+		a.metric = name
+	case "errors", "timeouts":
+		if a.eventType != TOTAL {
+			return a, fmt.Errorf("invalid metric in rule: %s: %s are counted for all events; remove the prefix", rule, name)
+		}
+		a.metric = name
+	default:
+		p, ok := assertPercentile(name)
+		if !ok {
+			return a, fmt.Errorf("invalid metric in rule: %s: %s: valid metrics: qps, tps, cps, min, max, pN (like p99), errors, timeouts", rule, ca.Metric)
+		}
+		a.metric = "pN"
+		a.p = p
+	}
+	return a, nil
+}
+
+// assertPercentile parses a percentile name like p99 (99), p99.9 (99.9), or
+// p999 (99.9): if there's no decimal point and the value is greater than 100,
+// the decimal point is after the first two digits, like the default P999.
+func assertPercentile(name string) (float64, bool) {
+	if !strings.HasPrefix(name, "p") || len(name) < 2 {
+		return 0, false
+	}
+	s := name[1:]
+	if !strings.Contains(s, ".") && len(s) > 2 {
+		s = s[0:2] + "." + s[2:]
+	}
+	p, err := strconv.ParseFloat(s, 64)
+	if err != nil || p <= 0 || p > 100 {
+		return 0, false
+	}
+	return p, true
+}
+
+// Eval evaluates the assertion against the summary stats of a stage (see
+// Collector.Summary). If the rule is for a trx that has no stats, the value
+// is zero.
+func (a Assertion) Eval(in Instance) AssertResult {
+	s := in.Total
+	if a.Trx != "" {
+		s = in.Trx[a.Trx]
+	}
+	var v float64
+	if s != nil {
+		switch a.metric {
+		case "rate":
+			if in.Seconds > 0 {
+				v = float64(s.N[a.eventType]) / in.Seconds
+			}
+		case "min":
+			v = float64(s.Min[a.eventType])
+		case "max":
+			v = float64(s.Max[a.eventType])
+		case "pN":
+			if s.N[a.eventType] > 0 {
+				v = float64(s.Percentiles(a.eventType, []float64{a.p})[0])
+			}
+		case "errors":
+			errors, _ := s.ErrorCount()
+			v = float64(errors)
+		case "timeouts":
+			_, timeouts := s.ErrorCount()
+			v = float64(timeouts)
+		}
+	}
+
+	var pass bool
+	switch a.Op {
+	case "<":
+		pass = v < a.Value
+	case "<=":
+		pass = v <= a.Value
+	case ">":
+		pass = v > a.Value
+	case ">=":
+		pass = v >= a.Value
+	case "==":
+		pass = v == a.Value
+	case "!=":
+		pass = v != a.Value
+	}
+	return AssertResult{Rule: a.Rule, Value: v, Pass: pass}
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Block, Inc.
+
+package stats_test
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/square/finch/stats"
+)
+
+func TestAssertion(t *testing.T) {
+	s := stats.NewStats()
+	for i := 0; i < 100; i++ {
+		s.Record(stats.READ, 1000) // 1ms
+	}
+	s.Record(stats.WRITE, 20000) // 20ms
+	s.Record(stats.COMMIT, 500)
+	s.Errors[1213] = 2
+
+	ro := stats.NewStats()
+	for i := 0; i < 100; i++ {
+		ro.Record(stats.READ, 1000)
+	}
+
+	in := stats.NewInstance("")
+	in.Seconds = 2.0
+	in.Total = s
+	in.Trx["read-only"] = ro
+
+	rules := []string{
+		"r_qps >= 50",
+		"max < 10ms",
+		"p99 < 5ms for trx read-only",
+		"errors == 0",
+		"tps == 0.5",
+		"timeouts == 0",
+		"w_p999 <= 21ms",
+	}
+	expect := []stats.AssertResult{
+		{Rule: rules[0], Value: 50, Pass: true},
+		{Rule: rules[1], Value: 20000, Pass: false},
+		{Rule: rules[2], Value: 977, Pass: true}, // histogram bucket,
+		{Rule: rules[3], Value: 2, Pass: false},
+		{Rule: rules[4], Value: 0.5, Pass: true},
+		{Rule: rules[5], Value: 0, Pass: true},
+		{Rule: rules[6], Value: 20422, Pass: true},
+	}
+	got := make([]stats.AssertResult, len(rules))
+	for i, rule := range rules {
+		a, err := stats.ParseAssertion(rule)
+		if err != nil {
+			t.Fatalf("%s: %s", rule, err)
+		}
+		got[i] = a.Eval(in)
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Logf("got: %+v", got)
+		t.Error(diff)
+	}
+
+	for _, rule := range []string{
+		"x_qps > 1",     // invalid prefix
+		"latency < 5ms", // invalid metric
+		"r_errors == 0", // errors are not per event type
+		"p0 < 5ms",      // invalid percentile
+		"p100.1 < 5ms",  // invalid percentile
+	} {
+		if _, err := stats.ParseAssertion(rule); err == nil {
+			t.Errorf("no error for invalid rule: %s", rule)
+		}
+	}
+}
@@ -25,6 +25,41 @@ type Instance struct {
 	Runtime  float64           // total elapsed seconds of benchmark
 	Total    *Stats            // all trx stats combined
 	Trx      map[string]*Stats // per trx stats
+	Target   map[string]*Stats // per MySQL target stats (see stats.Trx.Target)
+	Targets  map[string]uint   // number of clients per MySQL target
+	Metrics  []Metric          // from Samplers, if any
+	DDL      string            // DDL phase if Collector.MarkDDL, else empty
+}
+
+// DDL phases (Instance.DDL) of an interval relative to concurrent DDL
+// (config.stage.workload[].concurrent-ddl).
+const (
+	DDL_BEFORE = "before" // no DDL has run yet
+	DDL_DURING = "during" // DDL running or completed in the interval
+	DDL_AFTER  = "after"  // DDL completed in a previous interval
+)
+
+// ddlRank orders DDL phases for Combine: an interval is during DDL if any
+// instance is, else after if any instance is.
+var ddlRank = map[string]int{
+	"":         0,
+	DDL_BEFORE: 1,
+	DDL_AFTER:  2,
+	DDL_DURING: 3,
+}
+
+// Metric is a named value, like replication lag, sampled once per interval.
+type Metric struct {
+	Name  string
+	Value float64
+}
+
+// Sampler is sampled by the Collector at the end of each interval to report
+// metrics other than client stats, like replication lag (package lag). Samplers
+// run only on the local instance. Reporters print metrics after client stats
+// in the order returned.
+type Sampler interface {
+	Sample() []Metric
 }
 
 func NewInstance(hostname string) Instance {
@@ -32,6 +67,8 @@ func NewInstance(hostname string) Instance {
 		Hostname: hostname,
 		Total:    NewStats(),
 		Trx:      map[string]*Stats{},
+		Target:   map[string]*Stats{},
+		Targets:  map[string]uint{},
 	}
 }
 
@@ -47,6 +84,50 @@ func (in *Instance) Combine(from []Instance) {
 		in.Total.Combine(from[1+i].Total)
 		in.Clients += from[1+i].Clients
 	}
+	in.Metrics = metrics(from)
+	in.DDL = ddlPhase(from)
+
+	// Per-target stats, if any
+	if in.Target == nil {
+		in.Target = map[string]*Stats{}
+		in.Targets = map[string]uint{}
+	}
+	for target, s := range in.Target {
+		s.Reset()
+		in.Targets[target] = 0
+	}
+	for i := range from {
+		for target, s := range from[i].Target {
+			if _, ok := in.Target[target]; !ok {
+				in.Target[target] = NewStats()
+			}
+			in.Target[target].Combine(s)
+			in.Targets[target] += from[i].Targets[target]
+		}
+	}
+}
+
+// ddlPhase returns the DDL phase of instances in the same interval, or an empty
+// string if DDL phases aren't marked (see Collector.MarkDDL).
+func ddlPhase(from []Instance) string {
+	phase := ""
+	for i := range from {
+		if ddlRank[from[i].DDL] > ddlRank[phase] {
+			phase = from[i].DDL
+		}
+	}
+	return phase
+}
+
+// metrics returns the metrics from the first instance that has them, which is
+// the local instance because Samplers don't run on remote instances.
+func metrics(from []Instance) []Metric {
+	for i := range from {
+		if len(from[i].Metrics) > 0 {
+			return from[i].Metrics
+		}
+	}
+	return nil
 }
 
 // Collector collects and reports stats from local and remote instances.
@@ -63,6 +144,7 @@ type Collector struct {
 	start      time.Time // when Start was called, calculates Runtime
 	last       time.Time // when Collect was last called
 	reporters  []Reporter
+	samplers   []Sampler
 	finalChan  chan struct{}
 
 	*sync.Mutex
@@ -70,6 +152,9 @@ type Collector struct {
 	interval   []Instance // all Instance stats
 	n          uint       // index in interval
 	reported   time.Time  // when Report was last called
+	summary    Instance   // all intervals combined (see Summary)
+	asserts    []Assertion
+	ddl        string // current DDL phase if MarkDDL
 }
 
 func NewCollector(cfg config.Stats, hostname string, nInstances uint) (*Collector, error) {
@@ -81,6 +166,14 @@ func NewCollector(cfg config.Stats, hostname string, nInstances uint) (*Collecto
 		return nil, err
 	}
 
+	asserts := make([]Assertion, len(cfg.Assert))
+	for i := range cfg.Assert {
+		asserts[i], err = ParseAssertion(cfg.Assert[i])
+		if err != nil {
+			return nil, fmt.Errorf("stats.assert: %s", err)
+		}
+	}
+
 	return &Collector{
 		Freq:       freq,
 		stopChan:   make(chan struct{}),
@@ -91,6 +184,8 @@ func NewCollector(cfg config.Stats, hostname string, nInstances uint) (*Collecto
 		reporters:  reporters,
 		intervalNo: 1,
 		finalChan:  make(chan struct{}),
+		summary:    NewInstance(hostname),
+		asserts:    asserts,
 		Mutex:      &sync.Mutex{},
 	}, nil
 }
@@ -112,6 +207,15 @@ func (c *Collector) Watch(trx []*Trx) {
 
 	// This client is watching at least 1 set of trx stats
 	c.local.Clients += 1
+	for i := range trx {
+		if trx[i] != nil && trx[i].Target != "" {
+			c.local.Targets[trx[i].Target] += 1 // all trx in a client have the same target
+			if _, ok := c.local.Target[trx[i].Target]; !ok {
+				c.local.Target[trx[i].Target] = NewStats()
+			}
+			break
+		}
+	}
 	c.trx = append(c.trx, make([]*Trx, n))
 	c.stats = append(c.stats, make([]*Stats, n))
 	n = len(c.trx) - 1
@@ -127,6 +231,19 @@ func (c *Collector) Watch(trx []*Trx) {
 	}
 }
 
+// AddSampler adds a Sampler to sample at the end of each interval. It must be
+// called before Start.
+func (c *Collector) AddSampler(s Sampler) {
+	c.samplers = append(c.samplers, s)
+}
+
+// MarkDDL enables marking each interval with its DDL phase (Instance.DDL):
+// before, during, or after DDL that runs concurrently with DML. It must be
+// called before Start.
+func (c *Collector) MarkDDL() {
+	c.ddl = DDL_BEFORE
+}
+
 // Start starts metrics collection. It's called only once immediately before
 // starting clients in Stage.Run. If periodic stats are enabled (config.stats.freq > 0),
 // a goroutine is started to call Collect at the configured frequency, which is
@@ -313,6 +430,9 @@ func (c *Collector) Collect() bool {
 
 	// Combine all trx stats into total stats
 	c.local.Total.Reset()
+	for _, s := range c.local.Target {
+		s.Reset()
+	}
 	seen := map[string]bool{}
 	for i := range c.trx {
 		for j := range c.trx[i] {
@@ -328,6 +448,37 @@ func (c *Collector) Collect() bool {
 			// Merge stats into our local copies
 			c.local.Trx[trxName].Combine(s)
 			c.local.Total.Combine(s)
+			if target := c.trx[i][j].Target; target != "" {
+				c.local.Target[target].Combine(s)
+			}
+		}
+	}
+
+	// DDL phase: during if DDL is running now or completed in this interval
+	if c.ddl != "" {
+		running := false
+		for i := range c.trx {
+			for j := range c.trx[i] {
+				if c.trx[i][j].DDLRunning() {
+					running = true
+				}
+			}
+		}
+		switch {
+		case running || c.local.Total.N[DDL] > 0:
+			c.ddl = DDL_DURING
+		case c.ddl == DDL_DURING:
+			c.ddl = DDL_AFTER
+		}
+		c.local.DDL = c.ddl
+	}
+
+	// Sample other metrics, if any. New slice each interval because the previous
+	// one might not be reported yet if waiting for remote instances.
+	if len(c.samplers) > 0 {
+		c.local.Metrics = []Metric{}
+		for _, s := range c.samplers {
+			c.local.Metrics = append(c.local.Metrics, s.Sample()...)
 		}
 	}
 
@@ -388,8 +539,59 @@ func (c *Collector) Report(force bool) bool {
 	for _, r := range c.reporters {
 		r.Report(c.interval[0:c.n])
 	}
+	c.summarize(c.interval[0:c.n])
 	c.reported = time.Now()
 	c.intervalNo += 1
 	c.n = 0
 	return true // interval complete and reported
 }
+
+// summarize adds the stats from all instances in one interval to the summary.
+// Caller must lock c.
+func (c *Collector) summarize(from []Instance) {
+	if len(from) == 0 {
+		return
+	}
+	in := NewInstance("")
+	in.Combine(from)
+	c.summary.Hostname = in.Hostname
+	c.summary.Interval = in.Interval
+	c.summary.Seconds += in.Seconds
+	c.summary.Runtime = in.Runtime
+	if in.Clients > c.summary.Clients {
+		c.summary.Clients = in.Clients
+	}
+	c.summary.Total.Combine(in.Total)
+	for i := range from {
+		for trxName, s := range from[i].Trx {
+			if _, ok := c.summary.Trx[trxName]; !ok {
+				c.summary.Trx[trxName] = NewStats()
+			}
+			c.summary.Trx[trxName].Combine(s)
+		}
+	}
+}
+
+// Summary returns the stats from all intervals combined: Total is all stats,
+// Seconds is the sum of all intervals, Interval is the last interval number,
+// and Clients is the max in any interval. Total and Trx are set, not Target.
+// It's called after Stop, for example to summarize stages in a matrix.
+func (c *Collector) Summary() Instance {
+	c.Lock()
+	defer c.Unlock()
+	return c.summary
+}
+
+// Assert evaluates the stats.assert rules against the Summary. It's called
+// after Stop. It returns nil if there are no rules.
+func (c *Collector) Assert() []AssertResult {
+	if len(c.asserts) == 0 {
+		return nil
+	}
+	summary := c.Summary()
+	res := make([]AssertResult, len(c.asserts))
+	for i := range c.asserts {
+		res[i] = c.asserts[i].Eval(summary)
+	}
+	return res
+}
@@ -59,10 +59,10 @@ func TestCollector_1Client(t *testing.T) {
 	}
 
 	s1 := stats.NewStats()
-	// {READ, WRITE, COMMIT, TOTAL}
-	s1.N = []uint64{1, 0, 0, 1}
-	s1.Min = []int64{210, 0, 0, 210}
-	s1.Max = []int64{210, 0, 0, 210}
+	// {READ, WRITE, COMMIT, TOTAL, LOCKING_READ, CALL, BATCH, TRX, CONNECT, DDL}
+	s1.N = []uint64{1, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	s1.Min = []int64{210, 0, 0, 210, 0, 0, 0, 0, 0, 0}
+	s1.Max = []int64{210, 0, 0, 210, 0, 0, 0, 0, 0, 0}
 	// bucket 67 [208.929613, 218.776162)
 	s1.Buckets[stats.READ][67] = 1
 	s1.Buckets[stats.TOTAL][67] = 1
@@ -76,6 +76,8 @@ func TestCollector_1Client(t *testing.T) {
 			Runtime:  5.0,
 			Total:    s1,
 			Trx:      map[string]*stats.Stats{"t1": s1},
+			Target:   map[string]*stats.Stats{},
+			Targets:  map[string]uint{},
 		},
 	}
 
@@ -140,10 +142,10 @@ func TestCollector_2Clients(t *testing.T) {
 	}
 
 	s1 := stats.NewStats()
-	// {READ, WRITE, COMMIT, TOTAL}
-	s1.N = []uint64{4, 0, 0, 4}
-	s1.Min = []int64{100, 0, 0, 100}
-	s1.Max = []int64{222, 0, 0, 222}
+	// {READ, WRITE, COMMIT, TOTAL, LOCKING_READ, CALL, BATCH, TRX, CONNECT, DDL}
+	s1.N = []uint64{4, 0, 0, 4, 0, 0, 0, 0, 0, 0}
+	s1.Min = []int64{100, 0, 0, 100, 0, 0, 0, 0, 0, 0}
+	s1.Max = []int64{222, 0, 0, 222, 0, 0, 0, 0, 0, 0}
 	// 50 [95.499259, 100.000000)
 	// 53 [109.647820, 114.815362)
 	// 66 [199.526231, 208.929613)
@@ -168,6 +170,8 @@ func TestCollector_2Clients(t *testing.T) {
 			Runtime:  5.0,
 			Total:    s1,
 			Trx:      map[string]*stats.Stats{"t1": s1},
+			Target:   map[string]*stats.Stats{},
+			Targets:  map[string]uint{},
 		},
 	}
 
@@ -176,14 +180,23 @@ func TestCollector_2Clients(t *testing.T) {
 	if diff := deep.Equal(gotStats, expectStats); diff != nil {
 		t.Error(diff)
 	}
+
+	// Only one interval, so the summary is the same as the interval
+	sum := c.Summary()
+	if diff := deep.Equal(sum.Total, s1); diff != nil {
+		t.Error(diff)
+	}
+	if sum.Clients != 2 || sum.Interval != 1 {
+		t.Errorf("summary clients %d interval %d, expected 2 and 1", sum.Clients, sum.Interval)
+	}
 }
 
 func TestCollector_Combine(t *testing.T) {
 	s1 := stats.NewStats()
-	// {READ, WRITE, COMMIT, TOTAL}
-	s1.N = []uint64{4, 0, 0, 4}
-	s1.Min = []int64{100, 0, 0, 100}
-	s1.Max = []int64{222, 0, 0, 222}
+	// {READ, WRITE, COMMIT, TOTAL, LOCKING_READ, CALL, BATCH, TRX, CONNECT, DDL}
+	s1.N = []uint64{4, 0, 0, 4, 0, 0, 0, 0, 0, 0}
+	s1.Min = []int64{100, 0, 0, 100, 0, 0, 0, 0, 0, 0}
+	s1.Max = []int64{222, 0, 0, 222, 0, 0, 0, 0, 0, 0}
 	s1.Buckets[stats.READ][50] = 1
 	s1.Buckets[stats.READ][53] = 1
 	s1.Buckets[stats.READ][66] = 1
@@ -210,10 +223,10 @@ func TestCollector_Combine(t *testing.T) {
 	}
 
 	s2 := stats.NewStats()
-	// {READ, WRITE, COMMIT, TOTAL}
-	s2.N = []uint64{1, 0, 0, 1}
-	s2.Min = []int64{210, 0, 0, 210}
-	s2.Max = []int64{210, 0, 0, 210}
+	// {READ, WRITE, COMMIT, TOTAL, LOCKING_READ, CALL, BATCH, TRX, CONNECT, DDL}
+	s2.N = []uint64{1, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	s2.Min = []int64{210, 0, 0, 210, 0, 0, 0, 0, 0, 0}
+	s2.Max = []int64{210, 0, 0, 210, 0, 0, 0, 0, 0, 0}
 	s2.Buckets[stats.READ][67] = 1
 	s2.Buckets[stats.TOTAL][67] = 1
 	in2 := stats.Instance{
@@ -229,9 +242,9 @@ func TestCollector_Combine(t *testing.T) {
 	all.Combine([]stats.Instance{in1, in2})
 
 	expect := stats.NewStats()
-	expect.N = []uint64{5, 0, 0, 5}
-	expect.Min = []int64{100, 0, 0, 100}
-	expect.Max = []int64{222, 0, 0, 222}
+	expect.N = []uint64{5, 0, 0, 5, 0, 0, 0, 0, 0, 0}
+	expect.Min = []int64{100, 0, 0, 100, 0, 0, 0, 0, 0, 0}
+	expect.Max = []int64{222, 0, 0, 222, 0, 0, 0, 0, 0, 0}
 	expect.Buckets[stats.READ][50] = 1
 	expect.Buckets[stats.READ][53] = 1
 	expect.Buckets[stats.READ][66] = 1
@@ -247,3 +260,58 @@ func TestCollector_Combine(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestCollector_MarkDDL(t *testing.T) {
+	var phases []string
+	r := mock.StatsReporter{
+		ReportFunc: func(from []stats.Instance) {
+			phases = append(phases, from[0].DDL)
+		},
+	}
+	stats.Register("mock-ddl", r) // needs a unique reporter name
+
+	cfg := config.Stats{
+		Report: map[string]map[string]string{
+			"mock-ddl": nil,
+		},
+	}
+	c, err := stats.NewCollector(cfg, "local", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ddl := stats.NewTrx("alter")
+	dml := stats.NewTrx("dml")
+	c.Watch([]*stats.Trx{ddl})
+	c.Watch([]*stats.Trx{dml})
+	c.MarkDDL()
+	c.Start()
+
+	dml.Record(stats.WRITE, 100)
+	c.Collect() // before
+
+	ddl.DDL(true)
+	dml.Record(stats.WRITE, 500)
+	c.Collect() // during: DDL running
+
+	ddl.Record(stats.DDL, 2000000)
+	ddl.DDL(false)
+	c.Collect() // during: DDL completed in interval
+
+	dml.Record(stats.WRITE, 100)
+	c.Collect() // after
+
+	expect := []string{stats.DDL_BEFORE, stats.DDL_DURING, stats.DDL_DURING, stats.DDL_AFTER}
+	if diff := deep.Equal(phases, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// An interval is during DDL if any instance is
+	all := stats.NewInstance("")
+	all.Combine([]stats.Instance{
+		{Total: stats.NewStats(), DDL: stats.DDL_AFTER},
+		{Total: stats.NewStats(), DDL: stats.DDL_DURING},
+	})
+	if all.DDL != stats.DDL_DURING {
+		t.Errorf("combined DDL phase %s, expected %s", all.DDL, stats.DDL_DURING)
+	}
+}
@@ -6,15 +6,25 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/square/finch"
 )
 
 // CSV is a Reporter that prints stats to STDOUT. This is the default when
 // config.stats is not set.
 type CSV struct {
-	file *os.File
-	p    []float64
+	file       *os.File
+	p          []float64
+	events     []Event
+	eachTarget bool
+	throughput bool
+	header     string // printed on first Report because metrics are not known until then
+	nMetrics   int
+	ddl        bool // DDL phase column (see Collector.MarkDDL)
 }
 
 var _ Reporter = &CSV{}
@@ -38,25 +48,47 @@ func NewCSV(opts map[string]string) (*CSV, error) {
 	if err != nil {
 		return nil, err
 	}
+	events, err := ParseEvents(opts["events"])
+	if err != nil {
+		return nil, err
+	}
 
 	// @todo ensure at least 1 P enforced somewhere
 
-	fmt.Fprintf(f, Header,
+	header := fmt.Sprintf(Header,
 		strings.Join(sP, ","),                   // P total
 		strings.Join(withPrefix(sP, "r_"), ","), // read
 		strings.Join(withPrefix(sP, "w_"), ","), // write
 		strings.Join(withPrefix(sP, "c_"), ","), // commit
-	)
-	fmt.Fprintln(f)
+	) + eventHeader(events, sP, ",")
+	throughput := finch.Bool(opts["throughput"])
+	if throughput {
+		header += throughputHeader(",")
+	}
 
 	r := &CSV{
-		file: f,
-		p:    nP,
+		file:       f,
+		p:          nP,
+		events:     events,
+		eachTarget: finch.Bool(opts["each-target"]),
+		throughput: throughput,
+		header:     header,
 	}
 	return r, nil
 }
 
 func (r *CSV) Report(from []Instance) {
+	if r.header != "" {
+		m := metrics(from)
+		r.nMetrics = len(m)
+		r.ddl = ddlPhase(from) != ""
+		if r.ddl {
+			r.header += ",ddl"
+		}
+		fmt.Fprintln(r.file, r.header+metricHeader(m, ",")+","+TimeoutsHeader)
+		r.header = ""
+	}
+
 	total := NewStats()
 	total.Copy(from[0].Total)
 	clients := from[0].Clients
@@ -68,40 +100,56 @@ func (r *CSV) Report(from []Instance) {
 	if len(from) > 1 {
 		compute = fmt.Sprintf("%d combined", len(from))
 	}
+	in := from[0]
+	in.DDL = ddlPhase(from)
+	r.line(in, total, clients, compute, metricValues(metrics(from), ","))
 
-	var errorCount uint64
-	for _, v := range total.Errors {
-		errorCount += v
+	if !r.eachTarget {
+		return
 	}
+	all := NewInstance("")
+	all.Combine(from)
+	targets := make([]string, 0, len(all.Target))
+	for target := range all.Target {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		r.line(in, all.Target[target], all.Targets[target], compute+" "+target, strings.Repeat(",", r.nMetrics)) // metrics not per-target
+	}
+}
+
+func (r *CSV) line(in Instance, total *Stats, clients uint, compute, metrics string) {
+	errorCount, timeoutCount := total.ErrorCount()
 
 	// Fill in the line with values except the P percentile values, which is done below
 	// because there's a variable number of them
 	line := fmt.Sprintf(Fmt,
-		from[0].Interval,
-		from[0].Seconds, // duration (of interval)
-		from[0].Runtime,
+		in.Interval,
+		in.Seconds, // duration (of interval)
+		in.Runtime,
 		clients,
 
 		// TOTAL
-		int64(float64(total.N[TOTAL])/from[0].Seconds), // QPS
+		int64(float64(total.N[TOTAL])/in.Seconds), // QPS
 		total.Min[TOTAL],
 		// P
 		total.Max[TOTAL],
 
 		// READ
-		int64(float64(total.N[READ])/from[0].Seconds),
+		int64(float64(total.N[READ])/in.Seconds),
 		total.Min[READ],
 		// P
 		total.Max[READ],
 
 		// WRITE
-		int64(float64(total.N[WRITE])/from[0].Seconds),
+		int64(float64(total.N[WRITE])/in.Seconds),
 		total.Min[WRITE],
 		// P
 		total.Max[WRITE],
 
 		// COMMIT
-		int64(float64(total.N[COMMIT])/from[0].Seconds), // TPS
+		int64(float64(total.N[COMMIT])/in.Seconds), // TPS
 		total.Min[COMMIT],
 		// P
 		total.Max[COMMIT],
@@ -118,6 +166,16 @@ func (r *CSV) Report(from []Instance) {
 	line = strings.Replace(line, "P", intsToString(total.Percentiles(WRITE, r.p), ",", false), 1)
 	line = strings.Replace(line, "P", intsToString(total.Percentiles(COMMIT, r.p), ",", false), 1)
 
+	line += eventValues(total, in.Seconds, r.events, r.p, ",", false)
+	if r.throughput {
+		line += throughputValues(total, in.Seconds, ",", false)
+	}
+	if r.ddl {
+		line += "," + in.DDL
+	}
+	line += metrics
+	line += "," + strconv.FormatUint(timeoutCount, 10)
+
 	fmt.Fprintln(r.file, line)
 }
 
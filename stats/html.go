@@ -0,0 +1,320 @@
+// Copyright 2024 Block, Inc.
+
+package stats
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HTML is a Reporter that writes one self-contained HTML file when the stage
+// ends (Stop): inline SVG charts of QPS and percentiles per interval for all trx
+// and each trx, errors per interval, and the stage config. There are no network
+// assets (scripts, styles, fonts, or images), so the file can be shared as-is.
+//
+//	stats:
+//	  report:
+//	    html:
+//	      file: /tmp/read-only.html
+//	      percentiles: P95,P99,P999
+//
+// compute.Server sets the "stage" (name) and "config" (YAML) options.
+type HTML struct {
+	file      *os.File
+	sP        []string
+	p         []float64
+	stage     string
+	config    string
+	intervals []htmlInterval
+}
+
+var _ Reporter = &HTML{}
+
+// htmlInterval is one interval with all instances combined: only the values
+// charted, not the Stats, which are too large to keep for every interval.
+type htmlInterval struct {
+	runtime float64
+	errors  uint64 // errors and timeouts, all trx
+	trx     map[string]htmlPoint
+}
+
+type htmlPoint struct {
+	qps float64
+	p   []float64 // milliseconds, same order as HTML.p; nil if no events
+}
+
+// htmlAll is the pseudo trx name for all trx combined.
+const htmlAll = "All trx"
+
+func NewHTML(opts map[string]string) (*HTML, error) {
+	var f *os.File
+	var err error
+	fileName := opts["file"]
+	if fileName == "" {
+		// Use a random temp file
+		f, err = os.CreateTemp("", fmt.Sprintf("finch-benchmark-%s.html", strings.ReplaceAll(time.Now().Format(time.Stamp), " ", "_")))
+	} else {
+		f, err = os.OpenFile(fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("HTML file: %s\n", f.Name())
+
+	sP, nP, err := ParsePercentiles(opts["percentiles"])
+	if err != nil {
+		return nil, err
+	}
+
+	r := &HTML{
+		file:      f,
+		sP:        sP,
+		p:         nP,
+		stage:     opts["stage"],
+		config:    opts["config"],
+		intervals: []htmlInterval{},
+	}
+	return r, nil
+}
+
+func (r *HTML) Report(from []Instance) {
+	all := map[string]*Stats{htmlAll: NewStats()}
+	for i := range from {
+		all[htmlAll].Combine(from[i].Total)
+		for trxName, s := range from[i].Trx {
+			if _, ok := all[trxName]; !ok {
+				all[trxName] = NewStats()
+			}
+			all[trxName].Combine(s)
+		}
+	}
+	seconds := from[0].Seconds
+	if seconds == 0 {
+		seconds = 1 // avoid divide by zero
+	}
+	in := htmlInterval{
+		runtime: from[0].Runtime,
+		trx:     make(map[string]htmlPoint, len(all)),
+	}
+	errors, timeouts := all[htmlAll].ErrorCount()
+	in.errors = errors + timeouts
+	for trxName, s := range all {
+		pt := htmlPoint{qps: float64(s.N[TOTAL]) / seconds}
+		if s.N[TOTAL] > 0 {
+			q := s.Percentiles(TOTAL, r.p)
+			pt.p = make([]float64, len(q))
+			for i := range q {
+				pt.p[i] = float64(q[i]) / 1000 // μs -> ms
+			}
+		}
+		in.trx[trxName] = pt
+	}
+	r.intervals = append(r.intervals, in)
+}
+
+func (r *HTML) Stop() {
+	if _, err := r.file.WriteString(r.html()); err != nil {
+		log.Printf("Error writing HTML stats to %s: %s", r.file.Name(), err)
+	}
+	r.file.Close()
+}
+
+func (r *HTML) File() string {
+	return r.file.Name()
+}
+
+// html returns the HTML page.
+func (r *HTML) html() string {
+	var b strings.Builder
+	title := "Finch"
+	if r.stage != "" {
+		title += ": " + r.stage
+	}
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	b.WriteString("<style>\n" + htmlStyle + "</style>\n</head>\n<body>\n")
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(title))
+
+	runtime := 0.0
+	if n := len(r.intervals); n > 0 {
+		runtime = r.intervals[n-1].runtime
+	}
+	fmt.Fprintf(&b, "<p>%s &middot; %d intervals &middot; %.1fs runtime</p>\n", time.Now().Format(time.RFC3339), len(r.intervals), runtime)
+
+	if len(r.intervals) == 0 {
+		b.WriteString("<p>No stats.</p>\n")
+	} else {
+		x := make([]float64, len(r.intervals))
+		for i := range r.intervals {
+			x[i] = r.intervals[i].runtime
+		}
+		for _, trxName := range r.trxNames() {
+			fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(trxName))
+			qps := htmlLine{name: "QPS", y: make([]float64, len(r.intervals))}
+			p := make([]htmlLine, len(r.p))
+			for j := range r.p {
+				p[j] = htmlLine{name: r.sP[j] + " (ms)", y: make([]float64, len(r.intervals))}
+			}
+			for i := range r.intervals {
+				pt, ok := r.intervals[i].trx[trxName]
+				qps.y[i] = pt.qps // zero if !ok
+				for j := range p {
+					if ok && pt.p != nil {
+						p[j].y[i] = pt.p[j]
+					} else {
+						p[j].y[i] = math.NaN() // no value, not zero response time
+					}
+				}
+			}
+			svgChart(&b, x, []htmlLine{qps})
+			svgChart(&b, x, p)
+		}
+
+		b.WriteString("<h2>Errors</h2>\n")
+		errors := htmlLine{name: "errors", y: make([]float64, len(r.intervals))}
+		for i := range r.intervals {
+			errors.y[i] = float64(r.intervals[i].errors)
+		}
+		svgChart(&b, x, []htmlLine{errors})
+	}
+
+	if r.config != "" {
+		fmt.Fprintf(&b, "<h2>Config</h2>\n<pre>%s</pre>\n", html.EscapeString(r.config))
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// trxNames returns htmlAll first, then all trx names sorted.
+func (r *HTML) trxNames() []string {
+	seen := map[string]bool{htmlAll: true}
+	names := []string{}
+	for i := range r.intervals {
+		for trxName := range r.intervals[i].trx {
+			if !seen[trxName] {
+				seen[trxName] = true
+				names = append(names, trxName)
+			}
+		}
+	}
+	sort.Strings(names)
+	return append([]string{htmlAll}, names...)
+}
+
+const htmlStyle = `body { font-family: sans-serif; margin: 2em; color: #222; }
+h2 { margin-top: 1.5em; border-bottom: 1px solid #ccc; }
+svg { display: block; margin: 0.5em 0; }
+svg text { font-size: 11px; fill: #444; }
+pre { background: #f4f4f4; padding: 1em; overflow-x: auto; }
+`
+
+// htmlColors are the line colors, reused if there are more lines.
+var htmlColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b"}
+
+// htmlLine is one line in a chart. A NaN y value is a gap in the line.
+type htmlLine struct {
+	name string
+	y    []float64
+}
+
+const (
+	chartW = 800 // total width
+	chartH = 240 // total height
+	chartL = 70  // left margin: y-axis labels
+	chartR = 20  // right margin
+	chartT = 30  // top margin: legend
+	chartB = 30  // bottom margin: x-axis labels
+)
+
+// svgChart writes an inline SVG line chart: x is runtime (seconds) and lines
+// share one y-axis that starts at zero.
+func svgChart(b *strings.Builder, x []float64, lines []htmlLine) {
+	xMax := 0.0
+	for _, v := range x {
+		xMax = math.Max(xMax, v)
+	}
+	if xMax == 0 {
+		xMax = 1
+	}
+	yMax := 0.0
+	for _, l := range lines {
+		for _, v := range l.y {
+			if !math.IsNaN(v) {
+				yMax = math.Max(yMax, v)
+			}
+		}
+	}
+	yMax = niceMax(yMax)
+
+	w, h := float64(chartW-chartL-chartR), float64(chartH-chartT-chartB)
+	px := func(v float64) float64 { return chartL + v/xMax*w }
+	py := func(v float64) float64 { return chartT + (1-v/yMax)*h }
+
+	fmt.Fprintf(b, "<svg width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", chartW, chartH, chartW, chartH)
+
+	// Grid and axis labels
+	const ticks = 5
+	for i := 0; i <= ticks; i++ {
+		y := py(yMax * float64(i) / ticks)
+		fmt.Fprintf(b, "<line x1=\"%d\" y1=\"%.1f\" x2=\"%d\" y2=\"%.1f\" stroke=\"#ddd\"/>\n", chartL, y, chartW-chartR, y)
+		fmt.Fprintf(b, "<text x=\"%d\" y=\"%.1f\" text-anchor=\"end\">%s</text>\n", chartL-6, y+4, axisLabel(yMax*float64(i)/ticks))
+		x := px(xMax * float64(i) / ticks)
+		fmt.Fprintf(b, "<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\">%ss</text>\n", x, chartH-chartB+16, axisLabel(xMax*float64(i)/ticks))
+	}
+	fmt.Fprintf(b, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"#888\"/>\n", chartL, chartT, chartL, chartH-chartB)
+	fmt.Fprintf(b, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"#888\"/>\n", chartL, chartH-chartB, chartW-chartR, chartH-chartB)
+
+	for i, l := range lines {
+		color := htmlColors[i%len(htmlColors)]
+
+		// Legend
+		lx := chartL + i*120
+		fmt.Fprintf(b, "<rect x=\"%d\" y=\"8\" width=\"10\" height=\"10\" fill=\"%s\"/>\n", lx, color)
+		fmt.Fprintf(b, "<text x=\"%d\" y=\"17\">%s</text>\n", lx+14, html.EscapeString(l.name))
+
+		// Line, broken at NaN values, and a point for each value
+		points := []string{}
+		flush := func() {
+			if len(points) > 1 {
+				fmt.Fprintf(b, "<polyline fill=\"none\" stroke=\"%s\" stroke-width=\"1.5\" points=\"%s\"/>\n", color, strings.Join(points, " "))
+			}
+			points = points[:0]
+		}
+		for j, v := range l.y {
+			if math.IsNaN(v) {
+				flush()
+				continue
+			}
+			points = append(points, fmt.Sprintf("%.1f,%.1f", px(x[j]), py(v)))
+			fmt.Fprintf(b, "<circle cx=\"%.1f\" cy=\"%.1f\" r=\"2\" fill=\"%s\"><title>%s: %s at %ss</title></circle>\n",
+				px(x[j]), py(v), color, html.EscapeString(l.name), axisLabel(v), axisLabel(x[j]))
+		}
+		flush()
+	}
+	b.WriteString("</svg>\n")
+}
+
+// niceMax returns the smallest 1, 2, or 5 times a power of 10 >= v, so axis
+// ticks are round numbers. It returns 1 if v <= 0.
+func niceMax(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, f := range []float64{1, 2, 5, 10} {
+		if f*exp >= v {
+			return f * exp
+		}
+	}
+	return 10 * exp
+}
+
+// axisLabel formats a value without trailing zeros: 5, 2.5, 0.25, 10000.
+func axisLabel(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.3f", v), "0"), ".")
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Block, Inc.
+
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// JSON is a Reporter that writes all stats for each interval as one line of
+// JSON (JSON Lines format): a JSONInterval with every instance, trx, and target,
+// including histogram buckets, so it's machine-readable without loss.
+type JSON struct {
+	file *os.File
+	enc  *json.Encoder
+}
+
+var _ Reporter = &JSON{}
+
+// JSONInterval is one line written by the JSON reporter.
+type JSONInterval struct {
+	Interval  uint       `json:"interval"`
+	Time      time.Time  `json:"time"`
+	Instances []Instance `json:"instances"`
+}
+
+func NewJSON(opts map[string]string) (*JSON, error) {
+	var f *os.File
+	var err error
+	fileName := opts["file"]
+	if fileName == "" {
+		// Use a random temp file
+		f, err = os.CreateTemp("", fmt.Sprintf("finch-benchmark-%s.jsonl", strings.ReplaceAll(time.Now().Format(time.Stamp), " ", "_")))
+	} else {
+		f, err = os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("JSON file: %s\n", f.Name())
+	return &JSON{
+		file: f,
+		enc:  json.NewEncoder(f),
+	}, nil
+}
+
+func (r *JSON) Report(from []Instance) {
+	if err := r.enc.Encode(JSONInterval{Interval: from[0].Interval, Time: time.Now(), Instances: from}); err != nil {
+		log.Printf("Error writing JSON stats to %s: %s", r.file.Name(), err)
+	}
+}
+
+func (r *JSON) Stop() {
+	r.file.Close()
+}
+
+func (r *JSON) File() string {
+	return r.file.Name()
+}
@@ -17,6 +17,30 @@ import (
 var Header = "interval,duration,runtime,clients,QPS,min,%s,max,r_QPS,r_min,%s,r_max,w_QPS,w_min,%s,w_max,TPS,c_min,%s,c_max,errors,compute"
 var Fmt = "%d,%.1f,%.1f,%d,%d,%d,P,%d,%d,%d,P,%d,%d,%d,P,%d,%d,%d,P,%d,%d,%s"
 
+// TimeoutsHeader is the last column, after Header and all optional columns,
+// so column positions don't change for consumers that don't know about it.
+const TimeoutsHeader = "timeouts"
+
+// Event is an event type that reporters print only if enabled by the reporter
+// option "events", a CSV list of event names. Columns for enabled events are
+// appended after the default columns (after "compute") in the order listed.
+type Event struct {
+	Type   byte
+	Name   string // events option value
+	Prefix string // column prefix like "l_"
+	Rate   string // rate column like "l_QPS"
+}
+
+// Events are the optional event types.
+var Events = []Event{
+	{Type: LOCKING_READ, Name: "locking-read", Prefix: "l_", Rate: "l_QPS"},
+	{Type: CALL, Name: "call", Prefix: "call_", Rate: "call_QPS"},
+	{Type: BATCH, Name: "batch", Prefix: "b_", Rate: "b_QPS"},
+	{Type: TRX, Name: "trx", Prefix: "trx_", Rate: "trx_TPS"},
+	{Type: CONNECT, Name: "connect", Prefix: "conn_", Rate: "conn_CPS"},
+	{Type: DDL, Name: "ddl", Prefix: "ddl_", Rate: "ddl_QPS"},
+}
+
 var DefaultPercentiles = []float64{99.9}
 var DefaultPercentileNames = []string{"P999"}
 
@@ -64,6 +88,8 @@ func init() {
 	Register("stdout", f)
 	Register("server", f)
 	Register("csv", f)
+	Register("json", f)
+	Register("html", f)
 }
 
 type repo struct {
@@ -88,6 +114,10 @@ func (f factory) Make(name string, opts map[string]string) (Reporter, error) {
 		return NewServer(opts)
 	case "csv":
 		return NewCSV(opts)
+	case "json":
+		return NewJSON(opts)
+	case "html":
+		return NewHTML(opts)
 	}
 	return nil, fmt.Errorf("reporter %s not registered", name)
 }
@@ -119,6 +149,91 @@ func ParsePercentiles(pCSV string) ([]string, []float64, error) {
 	return s, p, nil
 }
 
+// ParseEvents parses the reporter option "events", a CSV list of Event names.
+func ParseEvents(eCSV string) ([]Event, error) {
+	if strings.TrimSpace(eCSV) == "" {
+		return nil, nil
+	}
+	events := []Event{}
+EVENTS:
+	for _, name := range strings.Split(eCSV, ",") {
+		name = strings.TrimSpace(name)
+		for _, e := range Events {
+			if e.Name == name {
+				events = append(events, e)
+				continue EVENTS
+			}
+		}
+		valid := make([]string, len(Events))
+		for i := range Events {
+			valid[i] = Events[i].Name
+		}
+		return nil, fmt.Errorf("invalid event: %s; valid events: %s", name, strings.Join(valid, ", "))
+	}
+	return events, nil
+}
+
+// eventHeader returns the header columns for events, each column preceded by sep.
+func eventHeader(events []Event, sP []string, sep string) string {
+	var s string
+	for _, e := range events {
+		cols := append([]string{e.Rate, e.Prefix + "min"}, withPrefix(sP, e.Prefix)...)
+		cols = append(cols, e.Prefix+"max")
+		s += sep + strings.Join(cols, sep)
+	}
+	return s
+}
+
+// eventValues returns the values for events in the same order as eventHeader,
+// each value preceded by sep.
+func eventValues(s *Stats, seconds float64, events []Event, p []float64, sep string, prettyPrint bool) string {
+	var line string
+	for _, e := range events {
+		n := []uint64{uint64(float64(s.N[e.Type]) / seconds), uint64(s.Min[e.Type])}
+		n = append(n, s.Percentiles(e.Type, p)...)
+		n = append(n, uint64(s.Max[e.Type]))
+		line += sep + intsToString(n, sep, prettyPrint)
+	}
+	return line
+}
+
+// ThroughputHeader is the header for the optional throughput columns (reporter
+// option "throughput"): rows and MB per second.
+var ThroughputHeader = []string{"rows_read/s", "rows_affected/s", "MB_recv/s", "MB_sent/s"}
+
+// throughputHeader returns the header columns for throughput, each column preceded by sep.
+func throughputHeader(sep string) string {
+	return sep + strings.Join(ThroughputHeader, sep)
+}
+
+// throughputValues returns the values for throughput in the same order as
+// throughputHeader, each value preceded by sep.
+func throughputValues(s *Stats, seconds float64, sep string, prettyPrint bool) string {
+	rows := intsToString([]uint64{
+		uint64(float64(s.RowsRead) / seconds),
+		uint64(float64(s.RowsAffected) / seconds),
+	}, sep, prettyPrint)
+	return sep + rows + fmt.Sprintf("%s%.1f%s%.1f", sep, float64(s.BytesRecv)/seconds/1048576, sep, float64(s.BytesSent)/seconds/1048576)
+}
+
+// metricHeader returns the header columns for metrics, each column preceded by sep.
+func metricHeader(metrics []Metric, sep string) string {
+	var s string
+	for _, m := range metrics {
+		s += sep + m.Name
+	}
+	return s
+}
+
+// metricValues returns the values for metrics, each value preceded by sep.
+func metricValues(metrics []Metric, sep string) string {
+	var s string
+	for _, m := range metrics {
+		s += sep + strconv.FormatFloat(m.Value, 'f', -1, 64)
+	}
+	return s
+}
+
 // intsToString returns []int{1,2,3} as "1,2,3" to replace P in Fmt.
 func intsToString(n []uint64, sep string, prettyPrint bool) string {
 	if len(n) == 0 {
@@ -3,7 +3,9 @@
 package stats_test
 
 import (
+	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/go-test/deep"
@@ -74,8 +76,8 @@ func TestCSV(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	expect := `interval,duration,runtime,clients,QPS,min,P999,max,r_QPS,r_min,r_P999,r_max,w_QPS,w_min,w_P999,w_max,TPS,c_min,c_P999,c_max,errors,compute
-1,2.0,2.0,1,3,110,389,390,1,110,185,190,1,210,294,290,1,310,389,390,0,local
+	expect := `interval,duration,runtime,clients,QPS,min,P999,max,r_QPS,r_min,r_P999,r_max,w_QPS,w_min,w_P999,w_max,TPS,c_min,c_P999,c_max,errors,compute,timeouts
+1,2.0,2.0,1,3,110,389,390,1,110,185,190,1,210,294,290,1,310,389,390,0,local,0
 `
 	if string(got) != expect {
 		t.Errorf("got:\n%s\nexpected:\n%s\n", string(got), expect)
@@ -86,3 +88,204 @@ func TestCSV(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestCSV_Events(t *testing.T) {
+	if _, err := stats.ParseEvents("locking-read,foo"); err == nil {
+		t.Error("no error for invalid event foo")
+	}
+
+	r, err := stats.NewCSV(map[string]string{"events": "locking-read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := r.File()
+	defer os.Remove(file)
+
+	s := stats.NewStats()
+	s.Record(stats.READ, 110)
+	s.Record(stats.READ, 190)
+	s.Record(stats.LOCKING_READ, 190)
+
+	r.Report([]stats.Instance{
+		{
+			Hostname: "local",
+			Clients:  1,
+			Interval: 1,
+			Seconds:  1.0,
+			Runtime:  1.0,
+			Total:    s,
+		},
+	})
+	r.Stop()
+
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Locking reads are also reads, so they're not counted twice in QPS
+	expect := `interval,duration,runtime,clients,QPS,min,P999,max,r_QPS,r_min,r_P999,r_max,w_QPS,w_min,w_P999,w_max,TPS,c_min,c_P999,c_max,errors,compute,l_QPS,l_min,l_P999,l_max,timeouts
+1,1.0,1.0,1,2,110,185,190,2,110,185,190,0,0,0,0,0,0,0,0,0,local,1,190,185,190,0
+`
+	if string(got) != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", string(got), expect)
+	}
+}
+
+func TestCSV_Metrics(t *testing.T) {
+	r, err := stats.NewCSV(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := r.File()
+	defer os.Remove(file)
+
+	s := stats.NewStats()
+	s.Record(stats.READ, 110)
+
+	// Remote instance first: metrics come from the instance that has them (local)
+	remote := stats.NewInstance("remote")
+	remote.Interval, remote.Seconds, remote.Runtime = 1, 1.0, 1.0
+	local := stats.NewInstance("local")
+	local.Interval, local.Seconds, local.Runtime = 1, 1.0, 1.0
+	local.Clients = 1
+	local.Total = s
+	local.Metrics = []stats.Metric{{Name: "lag_ms", Value: 1.5}, {Name: "lag_max_ms", Value: 20}}
+	r.Report([]stats.Instance{remote, local})
+	r.Stop()
+
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `interval,duration,runtime,clients,QPS,min,P999,max,r_QPS,r_min,r_P999,r_max,w_QPS,w_min,w_P999,w_max,TPS,c_min,c_P999,c_max,errors,compute,lag_ms,lag_max_ms,timeouts
+1,1.0,1.0,1,1,110,111,110,1,110,111,110,0,0,0,0,0,0,0,0,0,2 combined,1.5,20,0
+`
+	if string(got) != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", string(got), expect)
+	}
+}
+
+func TestCSV_Throughput(t *testing.T) {
+	r, err := stats.NewCSV(map[string]string{"throughput": "yes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := r.File()
+	defer os.Remove(file)
+
+	s := stats.NewStats()
+	s.Record(stats.READ, 110)
+	s.Add(100, 20, 1048576, 3*1048576)
+	s.Add(100, 0, 1048576, 1048576)
+
+	in := stats.NewInstance("local")
+	in.Interval, in.Seconds, in.Runtime = 1, 2.0, 2.0
+	in.Clients = 1
+	in.Total = s
+	r.Report([]stats.Instance{in})
+	r.Stop()
+
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `interval,duration,runtime,clients,QPS,min,P999,max,r_QPS,r_min,r_P999,r_max,w_QPS,w_min,w_P999,w_max,TPS,c_min,c_P999,c_max,errors,compute,rows_read/s,rows_affected/s,MB_recv/s,MB_sent/s,timeouts
+1,2.0,2.0,1,0,110,111,110,0,110,111,110,0,0,0,0,0,0,0,0,0,local,100,10,2.0,1.0,0
+`
+	if string(got) != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s\n", string(got), expect)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	r, err := stats.NewJSON(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := r.File()
+	defer os.Remove(file)
+
+	s := stats.NewStats()
+	s.Record(stats.READ, 110)
+	in := stats.NewInstance("local")
+	in.Interval, in.Seconds, in.Runtime, in.Clients = 1, 1.0, 1.0, 1
+	in.Total = s
+	r.Report([]stats.Instance{in})
+	in.Interval = 2
+	r.Report([]stats.Instance{in})
+	r.Stop()
+
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(bytes)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, expected 2 (one per interval)", len(lines))
+	}
+	var got stats.JSONInterval
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Interval != 2 || len(got.Instances) != 1 {
+		t.Fatalf("got interval %d with %d instances, expected interval 2 with 1", got.Interval, len(got.Instances))
+	}
+	if diff := deep.Equal(got.Instances[0].Total, s); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	r, err := stats.NewHTML(map[string]string{
+		"stage":       "read-only",
+		"config":      "stage:\n  name: read-only <test>\n",
+		"percentiles": "P50,P99",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := r.File()
+	defer os.Remove(file)
+
+	s := stats.NewStats()
+	s.Record(stats.READ, 110)
+	s.Errors[1213] = 1
+	in := stats.NewInstance("local")
+	in.Interval, in.Seconds, in.Runtime, in.Clients = 1, 1.0, 1.0, 1
+	in.Total = s
+	in.Trx["select.sql"] = s
+	r.Report([]stats.Instance{in})
+	in.Interval, in.Runtime = 2, 2.0
+	r.Report([]stats.Instance{in})
+	r.Stop()
+
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(bytes)
+	for _, expect := range []string{
+		"<title>Finch: read-only</title>",
+		"<h2>All trx</h2>",
+		"<h2>select.sql</h2>",
+		"<h2>Errors</h2>",
+		"P50 (ms)",
+		"P99 (ms)",
+		"<polyline",
+		"name: read-only &lt;test&gt;", // config is escaped
+	} {
+		if !strings.Contains(got, expect) {
+			t.Errorf("HTML does not contain %q", expect)
+		}
+	}
+	// QPS, percentiles: 2 charts for all trx and 2 for select.sql, plus errors
+	if n := strings.Count(got, "<svg "); n != 5 {
+		t.Errorf("got %d charts, expected 5", n)
+	}
+	// Self-contained: no network assets
+	for _, ref := range []string{"http:", "https:", "<script", "<link", "src="} {
+		if strings.Contains(got, ref) {
+			t.Errorf("HTML contains %q, expected no external references", ref)
+		}
+	}
+}
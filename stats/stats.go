@@ -5,15 +5,23 @@ package stats
 import (
 	"math"
 	"sync/atomic"
+
+	"github.com/square/finch"
 )
 
-var nEventTypes = 4 // number of event types:
+var nEventTypes = 10 // number of event types:
 
 const (
 	READ byte = iota
 	WRITE
 	COMMIT
 	TOTAL
+	LOCKING_READ // SELECT ... FOR UPDATE|SHARE; also recorded as READ
+	CALL         // CALL proc(); also recorded as TOTAL
+	BATCH        // round trip of batched statements (-- batch)
+	TRX          // finch trx (file): first statement through end of last statement
+	CONNECT      // MySQL connection (DB.Conn), initial and reconnect
+	DDL          // DDL statement (trx Meta.DDL); also recorded as TOTAL
 )
 
 // Stats are lock-free basic statistics: query count (N), min and max response time,
@@ -29,6 +37,12 @@ type Stats struct {
 	Max     []int64           // response time (μs)
 	N       []uint64          // number of events (queries)
 	Errors  map[uint16]uint64 // count MySQL error codes
+
+	// Throughput (see Add)
+	RowsRead     uint64 // rows returned by result sets
+	RowsAffected uint64 // rows affected by writes
+	BytesSent    uint64 // approximate: query length
+	BytesRecv    uint64 // approximate: result set column value length
 }
 
 func NewStats() *Stats {
@@ -74,9 +88,10 @@ func (s *Stats) Record(eventType byte, d int64) {
 	}
 	s.N[eventType]++
 
-	// Also record non-TOTAL events in the total stats. Since TOTAL events are
-	// recoded above, only do this for non-TOTAL events.
-	if eventType != TOTAL {
+	// Also record READ, WRITE, and COMMIT events in the total stats. Since TOTAL
+	// events are recoded above, only do this for those events. Other event types
+	// (see Events) are not queries or are recorded twice, like LOCKING_READ.
+	if eventType < TOTAL {
 		s.Buckets[TOTAL][n] += 1
 		if d < s.Min[TOTAL] || s.N[TOTAL] == 0 {
 			s.Min[TOTAL] = d
@@ -88,6 +103,15 @@ func (s *Stats) Record(eventType byte, d int64) {
 	}
 }
 
+// Add adds throughput: rows read and affected, and approximate bytes sent and
+// received, which are only the query and result set values, not the MySQL protocol.
+func (s *Stats) Add(rowsRead, rowsAffected, bytesSent, bytesRecv uint64) {
+	s.RowsRead += rowsRead
+	s.RowsAffected += rowsAffected
+	s.BytesSent += bytesSent
+	s.BytesRecv += bytesRecv
+}
+
 // Reset resets all values to zero.
 func (s *Stats) Reset() {
 	for i := 0; i < nEventTypes; i++ {
@@ -101,6 +125,10 @@ func (s *Stats) Reset() {
 	for k := range s.Errors {
 		s.Errors[k] = 0
 	}
+	s.RowsRead = 0
+	s.RowsAffected = 0
+	s.BytesSent = 0
+	s.BytesRecv = 0
 }
 
 // Copy copies all stats from c, overwriting all values in s. Calling Reset before
@@ -115,6 +143,10 @@ func (s *Stats) Copy(c *Stats) {
 	for k, v := range c.Errors {
 		s.Errors[k] = v
 	}
+	s.RowsRead = c.RowsRead
+	s.RowsAffected = c.RowsAffected
+	s.BytesSent = c.BytesSent
+	s.BytesRecv = c.BytesRecv
 }
 
 // Combine combines all stats from c. All values in s are adjusted with respect
@@ -125,7 +157,7 @@ func (s *Stats) Combine(c *Stats) {
 		for j := range s.Buckets[i] {
 			s.Buckets[i][j] += c.Buckets[i][j]
 		}
-		if c.Min[i] < s.Min[i] || s.N[i] == 0 {
+		if c.N[i] > 0 && (c.Min[i] < s.Min[i] || s.N[i] == 0) { // c.Min is 0 if c.N is 0
 			s.Min[i] = c.Min[i]
 		}
 		if c.Max[i] > s.Max[i] {
@@ -136,6 +168,20 @@ func (s *Stats) Combine(c *Stats) {
 	for k, v := range c.Errors {
 		s.Errors[k] += v
 	}
+	s.Add(c.RowsRead, c.RowsAffected, c.BytesSent, c.BytesRecv)
+}
+
+// ErrorCount returns the number of errors and, separately, the number of
+// statement timeouts: finch.ErrTimeout and MySQL error 3024 (MAX_EXECUTION_TIME).
+func (s *Stats) ErrorCount() (errors, timeouts uint64) {
+	for code, n := range s.Errors {
+		if code == finch.ErrTimeout || code == 3024 {
+			timeouts += n
+		} else {
+			errors += n
+		}
+	}
+	return
 }
 
 func (s Stats) Percentiles(eventType byte, p []float64) (q []uint64) {
@@ -188,11 +234,13 @@ func (s Stats) Percentiles(eventType byte, p []float64) (q []uint64) {
 // on-going stats recording by the Client. This is the other half of the lock-free
 // Stats design.
 type Trx struct {
-	Name string
-	a    *Stats
-	b    *Stats
-	sp   atomic.Pointer[Stats]
-	onA  bool
+	Name   string
+	Target string // MySQL address (see dbconn.Addr), if set
+	a      *Stats
+	b      *Stats
+	sp     atomic.Pointer[Stats]
+	onA    bool
+	ddl    atomic.Int32 // running DDL statements (see DDLRunning)
 }
 
 func NewTrx(name string) *Trx {
@@ -211,10 +259,29 @@ func (t *Trx) Record(eventType byte, d int64) {
 	t.sp.Load().Record(eventType, d)
 }
 
+func (t *Trx) Add(rowsRead, rowsAffected, bytesSent, bytesRecv uint64) {
+	t.sp.Load().Add(rowsRead, rowsAffected, bytesSent, bytesRecv)
+}
+
 func (t *Trx) Error(n uint16) {
 	t.sp.Load().Errors[n] += 1
 }
 
+// DDL marks the start (running=true) or end of a DDL statement, which the
+// Collector uses to mark DML stats before, during, and after concurrent DDL.
+func (t *Trx) DDL(running bool) {
+	if running {
+		t.ddl.Add(1)
+	} else {
+		t.ddl.Add(-1)
+	}
+}
+
+// DDLRunning returns true if a DDL statement is running.
+func (t *Trx) DDLRunning() bool {
+	return t.ddl.Load() > 0
+}
+
 func (t *Trx) Swap() *Stats {
 	// on A; switch to B
 	if t.onA {
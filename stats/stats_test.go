@@ -56,6 +56,15 @@ func TestBasicStats(t *testing.T) {
 		t.Errorf("got %d reads, expected 3", s.N[stats.READ])
 	}
 
+	// Trx event is not a query, so it's not recorded in total
+	s.Record(stats.TRX, 700)
+	if s.N[stats.TOTAL] != 5 {
+		t.Errorf("got %d events total after trx, expected 5", s.N[stats.TOTAL])
+	}
+	if s.N[stats.TRX] != 1 || s.Max[stats.TRX] != 700 {
+		t.Errorf("got %d trx max %d, expected 1 trx max 700", s.N[stats.TRX], s.Max[stats.TRX])
+	}
+
 	// @todo finish
 }
 
@@ -5,6 +5,7 @@ package stats
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 
@@ -19,14 +20,20 @@ import (
 //	  report:
 //	    stdout:
 //	      each-instance: true
+//	      each-target: false
 //	      combined: true
 type Stdout struct {
-	p        []float64
-	w        *tabwriter.Writer
-	header   string
-	all      *Instance
-	each     bool
-	combined bool
+	p          []float64
+	events     []Event
+	w          *tabwriter.Writer
+	header     string
+	all        *Instance
+	each       bool
+	eachTarget bool
+	combined   bool
+	throughput bool
+	ddl        bool // DDL phase column (see Collector.MarkDDL)
+	nMetrics   int
 }
 
 var _ Reporter = &Stdout{}
@@ -36,20 +43,31 @@ func NewStdout(opts map[string]string) (*Stdout, error) {
 	if err != nil {
 		return nil, err
 	}
+	events, err := ParseEvents(opts["events"])
+	if err != nil {
+		return nil, err
+	}
 	// Default header but s/,/\t/g
 	header := fmt.Sprintf(Header,
 		strings.Join(sP, ","),                   // P total
 		strings.Join(withPrefix(sP, "r_"), ","), // read
 		strings.Join(withPrefix(sP, "w_"), ","), // write
 		strings.Join(withPrefix(sP, "c_"), ","), // commit
-	)
+	) + eventHeader(events, sP, ",")
+	throughput := finch.Bool(opts["throughput"])
+	if throughput {
+		header += throughputHeader(",")
+	}
 	header = strings.ReplaceAll(header, ",", "\t")
 	r := &Stdout{
-		p:        nP,
-		w:        tabwriter.NewWriter(os.Stdout, 1, 0, 1, ' ', tabwriter.AlignRight|tabwriter.Debug),
-		header:   header,
-		each:     finch.Bool(opts["each-instance"]),
-		combined: finch.Bool(opts["combined"]),
+		p:          nP,
+		events:     events,
+		w:          tabwriter.NewWriter(os.Stdout, 1, 0, 1, ' ', tabwriter.AlignRight|tabwriter.Debug),
+		header:     header,
+		each:       finch.Bool(opts["each-instance"]),
+		eachTarget: finch.Bool(opts["each-target"]),
+		combined:   finch.Bool(opts["combined"]),
+		throughput: throughput,
 	}
 
 	_, ok1 := opts["each-instance"]
@@ -61,7 +79,9 @@ func NewStdout(opts map[string]string) (*Stdout, error) {
 
 	if r.combined {
 		r.all = &Instance{
-			Total: NewStats(),
+			Total:   NewStats(),
+			Target:  map[string]*Stats{},
+			Targets: map[string]uint{},
 			// We don't use Trx stats yet
 		}
 	}
@@ -69,7 +89,14 @@ func NewStdout(opts map[string]string) (*Stdout, error) {
 }
 
 func (r *Stdout) Report(from []Instance) {
-	fmt.Fprintln(r.w, r.header)
+	header := r.header
+	r.ddl = ddlPhase(from) != ""
+	if r.ddl {
+		header += "\tddl"
+	}
+	m := metrics(from)
+	r.nMetrics = len(m)
+	fmt.Fprintln(r.w, header+strings.ReplaceAll(metricHeader(m, ","), ",", "\t")+"\t"+TimeoutsHeader)
 	if r.each {
 		for i := range from {
 			r.print(&from[i])
@@ -84,16 +111,27 @@ func (r *Stdout) Report(from []Instance) {
 }
 
 func (r *Stdout) print(in *Instance) {
-	s := in.Total
-	var errorCount uint64
-	for _, v := range s.Errors {
-		errorCount += v
+	r.line(in, in.Total, in.Clients, in.Hostname)
+	if !r.eachTarget {
+		return
+	}
+	targets := make([]string, 0, len(in.Target))
+	for target := range in.Target {
+		targets = append(targets, target)
 	}
-	line := fmt.Sprintf("%d\t%.1f\t%.1f\t%d\t%s\t%s\tP\t%s\t%s\t%s\tP\t%s\t%s\t%s\tP\t%s\t%s\t%s\tP\t%s\t%s\t%s\n",
+	sort.Strings(targets)
+	for _, target := range targets {
+		r.line(in, in.Target[target], in.Targets[target], in.Hostname+" "+target)
+	}
+}
+
+func (r *Stdout) line(in *Instance, s *Stats, clients uint, compute string) {
+	errorCount, timeoutCount := s.ErrorCount()
+	line := fmt.Sprintf("%d\t%.1f\t%.1f\t%d\t%s\t%s\tP\t%s\t%s\t%s\tP\t%s\t%s\t%s\tP\t%s\t%s\t%s\tP\t%s\t%s\t%s",
 		in.Interval,
 		in.Seconds, // duration (of interval)
 		in.Runtime,
-		in.Clients,
+		clients,
 
 		// TOTAL
 		h.Comma(int64(float64(s.N[TOTAL])/in.Seconds)), // QPS
@@ -121,7 +159,7 @@ func (r *Stdout) print(in *Instance) {
 
 		h.Comma(int64(errorCount)),
 
-		in.Hostname,
+		compute,
 	)
 
 	// Replace P in Fmt with the CSV percentile values
@@ -130,6 +168,20 @@ func (r *Stdout) print(in *Instance) {
 	line = strings.Replace(line, "P", intsToString(s.Percentiles(WRITE, r.p), "\\t", true), 1)
 	line = strings.Replace(line, "P", intsToString(s.Percentiles(COMMIT, r.p), "\\t", true), 1)
 
+	line += eventValues(s, in.Seconds, r.events, r.p, "\t", true)
+	if r.throughput {
+		line += throughputValues(s, in.Seconds, "\t", true)
+	}
+	if r.ddl {
+		line += "\t" + in.DDL
+	}
+	if s == in.Total && len(in.Metrics) == r.nMetrics {
+		line += metricValues(in.Metrics, "\t")
+	} else {
+		line += strings.Repeat("\t", r.nMetrics) // not per-target, or not this instance
+	}
+	line += "\t" + h.Comma(int64(timeoutCount)) + "\n"
+
 	fmt.Fprintf(r.w, line)
 }
 
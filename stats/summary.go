@@ -0,0 +1,79 @@
+// Copyright 2024 Block, Inc.
+
+package stats
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	h "github.com/dustin/go-humanize"
+)
+
+// Summary is one table of stats for stages expanded from a matrix (config.stage.matrix):
+// one row per stage keyed by the matrix param values. Values are for the whole stage
+// (Collector.Summary), not per interval, so stages are comparable.
+type Summary struct {
+	params []string // matrix param names, in column order
+	rows   []summaryRow
+}
+
+type summaryRow struct {
+	values map[string]string // param => value
+	in     Instance
+}
+
+func NewSummary(params []string) *Summary {
+	return &Summary{
+		params: params,
+		rows:   []summaryRow{},
+	}
+}
+
+// Add adds the summary stats of a stage with the given matrix param values.
+func (s *Summary) Add(values map[string]string, in Instance) {
+	s.rows = append(s.rows, summaryRow{values: values, in: in})
+}
+
+// Len returns the number of stages (rows) added.
+func (s *Summary) Len() int {
+	return len(s.rows)
+}
+
+// Print prints the summary table to w.
+func (s *Summary) Print(w io.Writer) {
+	header := append(append([]string{}, s.params...),
+		"runtime", "clients", "QPS", "min", "P999", "max", "r_QPS", "w_QPS", "TPS", "c_P999", "errors")
+	tw := tabwriter.NewWriter(w, 1, 0, 1, ' ', tabwriter.AlignRight|tabwriter.Debug)
+	fmt.Fprintln(tw, strings.Join(header, "\t")+"\t")
+
+	for _, r := range s.rows {
+		t := r.in.Total
+		seconds := r.in.Seconds
+		if seconds == 0 {
+			seconds = 1 // avoid divide by zero; rates are zero anyway (no stats)
+		}
+		errors, timeouts := t.ErrorCount()
+		rate := func(n uint64) int64 { return int64(float64(n) / seconds) }
+		vals := make([]string, 0, len(header))
+		for _, p := range s.params {
+			vals = append(vals, r.values[p])
+		}
+		vals = append(vals,
+			fmt.Sprintf("%.1f", r.in.Seconds),
+			fmt.Sprintf("%d", r.in.Clients),
+			h.Comma(rate(t.N[TOTAL])),
+			h.Comma(t.Min[TOTAL]),
+			h.Comma(int64(t.Percentiles(TOTAL, DefaultPercentiles)[0])),
+			h.Comma(t.Max[TOTAL]),
+			h.Comma(rate(t.N[READ])),
+			h.Comma(rate(t.N[WRITE])),
+			h.Comma(rate(t.N[COMMIT])),
+			h.Comma(int64(t.Percentiles(COMMIT, DefaultPercentiles)[0])),
+			h.Comma(int64(errors+timeouts)),
+		)
+		fmt.Fprintln(tw, strings.Join(vals, "\t")+"\t")
+	}
+	tw.Flush()
+}
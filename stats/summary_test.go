@@ -0,0 +1,47 @@
+// Copyright 2024 Block, Inc.
+
+package stats_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/square/finch/stats"
+)
+
+func TestSummary(t *testing.T) {
+	s1 := stats.NewStats()
+	s1.Record(stats.READ, 110)
+	s1.Record(stats.COMMIT, 500)
+	in1 := stats.NewInstance("")
+	in1.Seconds, in1.Clients, in1.Total = 2.0, 1, s1
+
+	// Combining stats without commits must not change c_min
+	s2 := stats.NewStats()
+	s2.Record(stats.WRITE, 200)
+	s2.Record(stats.WRITE, 200)
+	s1.Combine(s2)
+	if s1.Min[stats.COMMIT] != 500 {
+		t.Errorf("c_min %d after combine, expected 500", s1.Min[stats.COMMIT])
+	}
+
+	in2 := stats.NewInstance("")
+	in2.Seconds, in2.Clients, in2.Total = 1.0, 2, stats.NewStats() // no stats
+
+	sum := stats.NewSummary([]string{"clients", "qps"})
+	sum.Add(map[string]string{"clients": "1", "qps": "100"}, in1)
+	sum.Add(map[string]string{"clients": "2", "qps": "100"}, in2)
+	if sum.Len() != 2 {
+		t.Errorf("Len %d, expected 2", sum.Len())
+	}
+
+	var buf bytes.Buffer
+	sum.Print(&buf)
+	expect := ` clients| qps| runtime| clients| QPS| min| P999| max| r_QPS| w_QPS| TPS| c_P999| errors|
+       1| 100|     2.0|       1|   2| 110|  489| 500|     0|     1|   0|    489|      0|
+       2| 100|     1.0|       2|   0|   0|    0|   0|     0|     0|   0|      0|      0|
+`
+	if buf.String() != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.String(), expect)
+	}
+}
@@ -0,0 +1,8 @@
+-- batch: 3
+BEGIN
+
+UPDATE t1 SET c=c+1 WHERE id=1
+
+SELECT c FROM t1 WHERE id=1
+
+COMMIT
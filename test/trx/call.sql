@@ -0,0 +1,7 @@
+CALL p1(1)
+
+-- save-columns: @c
+-- result-set: 2
+CALL p2(1)
+
+SELECT @c
@@ -0,0 +1,33 @@
+/* leading comment */ SELECT c FROM t1 WHERE id=1
+
+(SELECT c FROM t1 WHERE id=1) UNION (SELECT c FROM t1 WHERE id=2)
+
+WITH cte AS (SELECT id FROM t1 FOR UPDATE) SELECT * FROM cte
+
+WITH cte AS (SELECT id FROM t1) UPDATE t1, cte SET c=1 WHERE t1.id=cte.id
+
+SELECT c FROM t1 WHERE id=1 FOR UPDATE
+
+SELECT c FROM t1 WHERE id=1 LOCK IN SHARE MODE
+
+SELECT c FROM t1 WHERE note='for update'
+
+SHOW GLOBAL STATUS
+
+EXPLAIN SELECT c FROM t1
+
+DESC t1
+
+CALL p1()
+
+TABLE t1
+
+VALUES ROW(1, 2)
+
+START TRANSACTION
+
+COMMIT
+
+INSERT INTO t1 VALUES (1)
+
+TRUNCATE TABLE t2
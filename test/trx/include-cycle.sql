@@ -0,0 +1 @@
+-- include: include-cycle.sql
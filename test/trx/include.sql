@@ -0,0 +1,7 @@
+BEGIN
+
+-- include: include/common.sql fetch
+
+-- include: include/common.sql update
+
+COMMIT
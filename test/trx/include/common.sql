@@ -0,0 +1,8 @@
+-- block: fetch
+-- save-columns: @c
+select c from t1 where id=1
+-- end-block
+
+-- block: update
+update t1 set c=@c where id=1
+-- end-block
@@ -0,0 +1,5 @@
+-- timeout: 250ms max-execution-time
+select c from t1 where id=1
+
+-- timeout: 1s
+update t1 set c=c+1 where id=1
@@ -0,0 +1,188 @@
+// Copyright 2024 Block, Inc.
+
+package trx
+
+import (
+	"strings"
+)
+
+// class is the classification of a SQL statement returned by classify.
+type class struct {
+	Command     string // main command keyword, uppercase: "SELECT", "UPDATE", etc.
+	Pos         int    // byte offset of Command in the query
+	ResultSet   bool
+	Write       bool
+	DDL         bool
+	Begin       bool
+	Commit      bool
+	LockingRead bool // SELECT ... FOR UPDATE|SHARE or LOCK IN SHARE MODE
+	Call        bool // CALL proc()
+}
+
+// token is a keyword or identifier in a query. Strings, numbers, quoted
+// identifiers, comments, and symbols are not tokens, but parentheses set
+// depth: the number of open parentheses before the token.
+type token struct {
+	word  string // uppercase
+	pos   int    // byte offset in query
+	depth int
+}
+
+// tokenize returns the keywords and identifiers in query. It's a lightweight
+// lexer for classify, not a SQL parser: it only has to skip what's not a word
+// and track parentheses.
+func tokenize(query string) []token {
+	tokens := []token{}
+	depth := 0
+	n := len(query)
+	for i := 0; i < n; {
+		c := query[i]
+		switch {
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			// String or quoted identifier; backslash and doubled quote escape
+			i++
+			for i < n {
+				if query[i] == '\\' && c != '`' {
+					i += 2
+					continue
+				}
+				if query[i] == c {
+					if i+1 < n && query[i+1] == c {
+						i += 2 // doubled quote
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++ // closing quote
+		case c == '#' || (c == '-' && strings.HasPrefix(query[i:], "-- ")):
+			// Comment to end of line
+			for i < n && query[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < n && query[i+1] == '*':
+			// Comment, including optimizer hints /*+ */ and /*! */
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens // unterminated comment
+			}
+			i += 2 + end + 2
+		case isWordStart(c):
+			start := i
+			for i < n && isWordChar(query[i]) {
+				i++
+			}
+			tokens = append(tokens, token{
+				word:  strings.ToUpper(query[start:i]),
+				pos:   start,
+				depth: depth,
+			})
+		case c == '@':
+			// Variable or Finch data key (@d): skip, not a keyword
+			i++
+			for i < n && (isWordChar(query[i]) || query[i] == '-') {
+				i++
+			}
+		case c >= '0' && c <= '9':
+			for i < n && (isWordChar(query[i]) || query[i] == '.') {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+func isWordStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+}
+
+func isWordChar(c byte) bool {
+	return isWordStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
+
+// classify classifies a query by its main command, which is the first keyword
+// unless the query begins with a common table expression (WITH).
+func classify(query string) class {
+	var c class
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return c
+	}
+
+	// Find the main command. For WITH, it's the first statement keyword after
+	// the CTEs at the same depth as WITH, e.g. the SELECT in:
+	// WITH cte AS (SELECT ...) SELECT ...
+	main := 0
+	if tokens[0].word == "WITH" {
+		for i := 1; i < len(tokens); i++ {
+			if tokens[i].depth != tokens[0].depth {
+				continue
+			}
+			switch tokens[i].word {
+			case "SELECT", "TABLE", "VALUES", "UPDATE", "DELETE", "INSERT", "REPLACE":
+				main = i
+			}
+			if main > 0 {
+				break
+			}
+		}
+	}
+	c.Command = tokens[main].word
+	c.Pos = tokens[main].pos
+
+	switch c.Command {
+	case "SELECT", "TABLE", "VALUES", "WITH":
+		c.ResultSet = true
+		c.LockingRead = lockingRead(tokens[main:], tokens[main].depth)
+	case "SHOW", "EXPLAIN", "DESCRIBE", "DESC", "HELP":
+		c.ResultSet = true
+	case "CALL":
+		c.ResultSet = true // procedures can return result sets
+		c.Call = true
+	case "BEGIN":
+		c.Begin = true
+	case "START":
+		if len(tokens) > 1 && tokens[1].word == "TRANSACTION" {
+			c.Begin = true
+		}
+	case "COMMIT":
+		c.Commit = true
+	case "INSERT", "UPDATE", "DELETE", "REPLACE", "LOAD":
+		c.Write = true
+	case "ALTER", "CREATE", "DROP", "RENAME", "TRUNCATE":
+		c.DDL = true
+	}
+	return c
+}
+
+// lockingRead returns true if tokens at the given depth have a locking clause:
+// FOR UPDATE, FOR SHARE, or LOCK IN SHARE MODE.
+func lockingRead(tokens []token, depth int) bool {
+	for i := 0; i < len(tokens)-1; i++ {
+		if tokens[i].depth != depth {
+			continue
+		}
+		switch tokens[i].word {
+		case "FOR":
+			if w := tokens[i+1].word; w == "UPDATE" || w == "SHARE" {
+				return true
+			}
+		case "LOCK":
+			if i+3 < len(tokens) && tokens[i+1].word == "IN" && tokens[i+2].word == "SHARE" && tokens[i+3].word == "MODE" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Block, Inc.
+
+package trx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/square/finch/config"
+)
+
+// parseInclude parses the value of an include directive, "FILE [BLOCK]", and
+// returns FILE relative to dir (the directory of the including file) and BLOCK,
+// which is empty if not specified.
+func parseInclude(directive, dir string) (string, string, error) {
+	f := strings.Fields(directive)
+	if len(f) < 1 || len(f) > 2 {
+		return "", "", fmt.Errorf("'%s' split into %d fields, expected FILE [BLOCK]", directive, len(f))
+	}
+	file := f[0]
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	block := ""
+	if len(f) == 2 {
+		block = f[1]
+	}
+	return file, block, nil
+}
+
+func isBlockMarker(line string) bool {
+	return strings.HasPrefix(line, BLOCK) || line == END_BLOCK
+}
+
+// readInclude returns the trimmed lines of an included trx file. If block is
+// not empty, only the lines in that named block are returned. Include directives
+// in the file are interpolated with params and expanded recursively; seen detects
+// include cycles.
+func readInclude(file, block string, params map[string]string, seen map[string]bool) ([]string, error) {
+	if seen[file] {
+		return nil, fmt.Errorf("include cycle: %s already included", file)
+	}
+	seen[file] = true
+	defer delete(seen, file) // same file can be included more than once, just not recursively
+
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	lines := []string{}
+	inBlock := false
+	found := false
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "-- EOF" {
+			break
+		}
+
+		// Named blocks
+		if strings.HasPrefix(line, BLOCK) {
+			if inBlock {
+				return nil, fmt.Errorf("%s: nested blocks are not allowed", file)
+			}
+			inBlock = true
+			if block != "" && strings.TrimSpace(strings.TrimPrefix(line, BLOCK)) == block {
+				found = true
+			}
+			continue
+		}
+		if line == END_BLOCK {
+			if !inBlock {
+				return nil, fmt.Errorf("%s: %s without %s", file, END_BLOCK, BLOCK)
+			}
+			inBlock = false
+			if found && block != "" {
+				break // done reading the named block
+			}
+			continue
+		}
+		if block != "" && !found {
+			continue // not in the named block
+		}
+
+		// Nested include
+		if strings.HasPrefix(line, INCLUDE) {
+			directive, err := config.Vars(strings.TrimSpace(strings.TrimPrefix(line, INCLUDE)), params, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", file, err)
+			}
+			nfile, nblock, err := parseInclude(directive, filepath.Dir(file))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", file, err)
+			}
+			nested, err := readInclude(nfile, nblock, params, seen)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, "")
+			lines = append(lines, nested...)
+			lines = append(lines, "")
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if block != "" && !found {
+		return nil, fmt.Errorf("%s: block %s not found", file, block)
+	}
+	return lines, nil
+}
+
+// Expand returns the trx file with all include directives replaced by the
+// included lines. It's used by compute.API to send trx files to remote compute
+// instances, which don't have the included files.
+func Expand(file string, params map[string]string) ([]byte, error) {
+	lines, err := readInclude(filepath.Clean(file), "", params, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
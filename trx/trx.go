@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -17,6 +18,7 @@ import (
 	"github.com/square/finch"
 	"github.com/square/finch/config"
 	"github.com/square/finch/data"
+	"github.com/square/finch/idle"
 	"github.com/square/finch/limit"
 )
 
@@ -28,6 +30,19 @@ const (
 
 const EXPLICIT_CALL_SUFFIX = "()"
 
+// Include directive and named blocks in included trx files:
+//
+//	-- include: common.sql fetch-customer
+//
+//	-- block: fetch-customer
+//	SELECT ...
+//	-- end-block
+const (
+	INCLUDE   = "-- include:"
+	BLOCK     = "-- block:"
+	END_BLOCK = "-- end-block"
+)
+
 var DataKeyPattern = regexp.MustCompile(`@[\w_-]+(?:\(\))?`)
 var ExplicitCallPattern = regexp.MustCompile(`@[\w_-]+\(\)`)
 
@@ -44,13 +59,18 @@ type Statement struct {
 	Trx          string
 	Query        string
 	ResultSet    bool
+	LockingRead  bool // SELECT ... FOR UPDATE|SHARE
+	Call         bool // CALL proc(): drain all result sets
+	CallResult   int  // CALL result set (1-based) for save-columns
+	Batch        int  // execute this and next Batch-1 statements in one round trip
 	Prepare      bool
 	PrepareMulti int
 	Begin        bool
 	Commit       bool
 	Write        bool
 	DDL          bool
-	Idle         time.Duration
+	Idle         idle.Time
+	Timeout      time.Duration
 	Inputs       []string // data keys (number of values)
 	Outputs      []string // data keys save-results|columns and save-insert-id
 	InsertId     string   // data key (special output)
@@ -59,7 +79,8 @@ type Statement struct {
 }
 
 type Meta struct {
-	DDL bool
+	DDL   bool
+	Batch bool // true if any statement is batched (-- batch)
 }
 
 // Load loads all trx files and returns a Set representing all parsed trx.
@@ -100,11 +121,12 @@ type File struct {
 	set    *Set              // trx set for the stage, what File.Load fills in
 	params map[string]string // stage.params: user-defined value interpolation
 	// --
-	lb      lineBuf        // save lines until a complete statement is read
-	colRefs map[string]int // column ref counts to detect unused ones
-	stmtNo  uint           // 1-indexed in file (not a line number; not an index into stmt)
-	stmts   []*Statement   // all statements in this file
-	hasDDL  bool           // true if any statement is DDL
+	lb       lineBuf        // save lines until a complete statement is read
+	colRefs  map[string]int // column ref counts to detect unused ones
+	stmtNo   uint           // 1-indexed in file (not a line number; not an index into stmt)
+	stmts    []*Statement   // all statements in this file
+	hasDDL   bool           // true if any statement is DDL
+	hasBatch bool           // true if any statement is batched
 }
 
 func NewFile(cfg config.Trx, set *Set, params map[string]string) *File {
@@ -161,10 +183,15 @@ func (f *File) Load() error {
 		log.Fatal(err) // shouldn't happen
 	}
 
+	if err := f.batches(); err != nil {
+		return err
+	}
+
 	f.set.Order = append(f.set.Order, f.cfg.Name)
 	f.set.Statements[f.cfg.Name] = f.stmts
 	f.set.Meta[f.cfg.Name] = Meta{
-		DDL: f.hasDDL,
+		DDL:   f.hasDDL,
+		Batch: f.hasBatch,
 	}
 
 	return nil
@@ -180,6 +207,12 @@ func (f *File) line(line string) error {
 			if line == "-- EOF" {
 				return ErrEOF
 			}
+			if isBlockMarker(line) {
+				return nil // only used when included
+			}
+			if strings.HasPrefix(line, INCLUDE) {
+				return f.include(line)
+			}
 			mod, err := config.Vars(strings.TrimSpace(strings.TrimPrefix(line, "--")), f.params, true)
 			if err != nil {
 				return fmt.Errorf("parsing modifier '%s' on line %d: %s", line, f.lb.n, err)
@@ -214,9 +247,77 @@ func (f *File) line(line string) error {
 	return nil
 }
 
+// batches validates statements batched by the batch modifier. A batch is sent
+// as one multi-statement query, so its statements must be in the same trx file
+// and cannot be prepared or use modifiers that need a per-statement result.
+func (f *File) batches() error {
+	for i := 0; i < len(f.stmts); i++ {
+		n := f.stmts[i].Batch
+		if n == 0 {
+			continue
+		}
+		if i+n > len(f.stmts) {
+			return fmt.Errorf("batch: %d on statement %d: only %d statements in the batch (the rest of the trx file)", n, i+1, len(f.stmts)-i)
+		}
+		for j := i; j < i+n; j++ {
+			s := f.stmts[j]
+			var invalid string
+			switch {
+			case j > i && s.Batch > 0:
+				invalid = "batch"
+			case s.Prepare:
+				invalid = "prepare"
+			case s.Idle != nil:
+				invalid = "idle"
+			case s.Limit != nil:
+				invalid = "rows, table-size, or database-size"
+			case s.InsertId != "":
+				invalid = "save-insert-id"
+			case s.Call:
+				invalid = "CALL"
+			case s.DDL:
+				invalid = "DDL"
+			}
+			if invalid != "" {
+				return fmt.Errorf("batch: %d on statement %d: statement %d in batch has %s, which is not allowed in a batch", n, i+1, j+1, invalid)
+			}
+		}
+		f.hasBatch = true
+		i += n - 1
+	}
+	return nil
+}
+
+// include handles an include directive: "-- include: FILE [BLOCK]". The lines
+// of FILE (or only the named BLOCK in FILE) are parsed as if they were written
+// in place of the directive.
+func (f *File) include(line string) error {
+	if f.lb.str != "" || len(f.lb.mods) > 0 {
+		return fmt.Errorf("include on line %d must be separated from the previous statement by an empty line", f.lb.n)
+	}
+	directive, err := config.Vars(strings.TrimSpace(strings.TrimPrefix(line, INCLUDE)), f.params, false)
+	if err != nil {
+		return fmt.Errorf("parsing include '%s' on line %d: %s", line, f.lb.n, err)
+	}
+	file, block, err := parseInclude(directive, filepath.Dir(f.cfg.File))
+	if err != nil {
+		return fmt.Errorf("invalid include on line %d: %s", f.lb.n, err)
+	}
+	lines, err := readInclude(file, block, f.params, map[string]bool{filepath.Clean(f.cfg.File): true})
+	if err != nil {
+		return fmt.Errorf("include on line %d: %s", f.lb.n, err)
+	}
+	finch.Debug("include %s %s: %d lines", file, block, len(lines))
+	for _, l := range lines {
+		if err := f.line(l); err != nil {
+			return err
+		}
+	}
+	return f.line("") // end last included statement
+}
+
 var reKeyVal = regexp.MustCompile(`([\w_-]+)(?:\:\s*(\w+))?`)
 var reCSV = regexp.MustCompile(`\/\*\!csv\s+(\d+)\s+(.+)\*\/`)
-var reFirstWord = regexp.MustCompile(`^(\w+)`)
 
 func (f *File) statements() ([]*Statement, error) {
 	f.stmtNo++
@@ -231,18 +332,15 @@ func (f *File) statements() ([]*Statement, error) {
 	// Switches
 	// ----------------------------------------------------------------------
 
-	// @todo regexp to extract first word
-	com := strings.ToUpper(reFirstWord.FindString(query))
-	switch com {
-	case "SELECT":
-		s.ResultSet = true
-	case "BEGIN", "START":
-		s.Begin = true // used to rate limit trx per second (TPS) in client/client.go
-	case "COMMIT":
-		s.Commit = true // used to measure TPS rate in client/client.go
-	case "INSERT", "UPDATE", "DELETE", "REPLACE":
-		s.Write = true
-	case "ALTER", "CREATE", "DROP", "RENAME", "TRUNCATE":
+	c := classify(query)
+	finch.Debug("class: %+v", c)
+	s.ResultSet = c.ResultSet
+	s.LockingRead = c.LockingRead
+	s.Call = c.Call
+	s.Begin = c.Begin   // used to rate limit trx per second (TPS) in client/client.go
+	s.Commit = c.Commit // used to measure TPS rate in client/client.go
+	s.Write = c.Write
+	if c.DDL {
 		finch.Debug("DDL")
 		s.DDL = true    // statement is DDL
 		f.hasDDL = true // trx has DDL
@@ -252,6 +350,7 @@ func (f *File) statements() ([]*Statement, error) {
 	// Modifiers: --prepare, --table-size, etc.
 	// ----------------------------------------------------------------------
 
+	maxExecTime := false
 	for _, mod := range f.lb.mods {
 		m := strings.Fields(mod)
 		finch.Debug("mod: '%v' %#v", mod, m)
@@ -263,11 +362,32 @@ func (f *File) statements() ([]*Statement, error) {
 		case "prepare", "prepared":
 			s.Prepare = true
 		case "idle":
-			d, err := time.ParseDuration(m[1])
+			t, err := idle.Parse(strings.Join(m[1:], " "))
 			if err != nil {
 				return nil, fmt.Errorf("invalid idle modifier: '%s': %s", mod, err)
 			}
-			s.Idle = d
+			s.Idle = t
+		case "timeout":
+			if len(m) < 2 || len(m) > 3 {
+				return nil, fmt.Errorf("invalid timeout modifier: split %d fields, expected 2 or 3: %s", len(m), mod)
+			}
+			d, err := time.ParseDuration(m[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid timeout modifier: '%s': %s", mod, err)
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("invalid timeout modifier: '%s': must be greater than zero", mod)
+			}
+			s.Timeout = d
+			if len(m) == 3 {
+				if m[2] != "max-execution-time" {
+					return nil, fmt.Errorf("invalid timeout modifier: '%s': unknown option %s; expected max-execution-time", mod, m[2])
+				}
+				if c.Command != "SELECT" {
+					return nil, fmt.Errorf("invalid timeout modifier: '%s': max-execution-time only allowed on SELECT", mod)
+				}
+				maxExecTime = true
+			}
 		case "rows":
 			max, err := strconv.ParseUint(m[1], 10, 64)
 			if err != nil {
@@ -319,6 +439,27 @@ func (f *File) statements() ([]*Statement, error) {
 				}
 				s.Outputs = append(s.Outputs, dataKey)
 			}
+		case "batch":
+			if len(m) != 2 {
+				return nil, fmt.Errorf("invalid batch modifier: split %d fields, expected 2: %s", len(m), mod)
+			}
+			n, err := strconv.Atoi(m[1])
+			if err != nil || n < 2 {
+				return nil, fmt.Errorf("invalid batch modifier: '%s': must be an integer >= 2", mod)
+			}
+			s.Batch = n
+		case "result-set":
+			if !s.Call {
+				return nil, fmt.Errorf("result-set modifier only allowed on CALL")
+			}
+			if len(m) != 2 {
+				return nil, fmt.Errorf("invalid result-set modifier: split %d fields, expected 2: %s", len(m), mod)
+			}
+			n, err := strconv.Atoi(m[1])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid result-set modifier: '%s': must be an integer >= 1", mod)
+			}
+			s.CallResult = n
 		case "copies":
 			n, err := strconv.Atoi(m[1])
 			if err != nil {
@@ -366,6 +507,22 @@ func (f *File) statements() ([]*Statement, error) {
 		}
 	}
 
+	if s.Call && s.CallResult == 0 {
+		s.CallResult = 1 // save-columns from first result set by default
+	}
+
+	// ----------------------------------------------------------------------
+	// Optimizer hint: SELECT /*+ MAX_EXECUTION_TIME(N) */
+	// ----------------------------------------------------------------------
+	if maxExecTime {
+		ms := s.Timeout.Milliseconds()
+		if ms < 1 {
+			ms = 1 // MySQL minimum
+		}
+		end := c.Pos + len(c.Command)
+		query = fmt.Sprintf("%s /*+ MAX_EXECUTION_TIME(%d) */%s", query[0:end], ms, query[end:])
+	}
+
 	// ----------------------------------------------------------------------
 	// Replace /*!copy-number*/
 	// ----------------------------------------------------------------------
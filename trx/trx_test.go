@@ -3,7 +3,10 @@
 package trx_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-test/deep"
 
@@ -357,6 +360,265 @@ func TestLoad_COPY_NUMBER(t *testing.T) {
 	}
 }
 
+func TestLoad_Timeout(t *testing.T) {
+	// -- timeout sets Statement.Timeout, and max-execution-time adds the
+	// optimizer hint to the SELECT
+	expect := &trx.Set{
+		Order: []string{"timeout"},
+		Statements: map[string][]*trx.Statement{
+			"timeout": []*trx.Statement{
+				{
+					Trx:       "timeout",
+					Query:     "select /*+ MAX_EXECUTION_TIME(250) */ c from t1 where id=1",
+					ResultSet: true,
+					Timeout:   250 * time.Millisecond,
+				},
+				{
+					Trx:     "timeout",
+					Query:   "update t1 set c=c+1 where id=1",
+					Write:   true,
+					Timeout: 1 * time.Second,
+				},
+			},
+		},
+		Data: &data.Scope{
+			Keys:     map[string]data.Key{}, // no @d
+			CopiedAt: map[string]finch.RunLevel{},
+		},
+		Meta: map[string]trx.Meta{
+			"timeout": {DDL: false},
+		},
+	}
+
+	trxList := []config.Trx{
+		{
+			Name: "timeout", // must set because we don't call Validate
+			File: "../test/trx/timeout.sql",
+		},
+	}
+
+	scope := data.NewScope()
+	got, err := trx.Load(trxList, scope, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+		t.Logf("got: %#v", got)
+	}
+}
+
+func TestLoad_Classify(t *testing.T) {
+	// Statements are classified by their main command, not just the first word
+	type class struct {
+		ResultSet   bool
+		LockingRead bool
+		Write       bool
+		DDL         bool
+		Begin       bool
+		Commit      bool
+	}
+	expect := []class{
+		{ResultSet: true},                    // /* leading comment */ SELECT
+		{ResultSet: true},                    // (SELECT) UNION (SELECT)
+		{ResultSet: true},                    // WITH ... SELECT, FOR UPDATE only in CTE
+		{Write: true},                        // WITH ... UPDATE
+		{ResultSet: true, LockingRead: true}, // FOR UPDATE
+		{ResultSet: true, LockingRead: true}, // LOCK IN SHARE MODE
+		{ResultSet: true},                    // 'for update' string
+		{ResultSet: true},                    // SHOW
+		{ResultSet: true},                    // EXPLAIN
+		{ResultSet: true},                    // DESC
+		{ResultSet: true},                    // CALL (also Call, see TestLoad_Call)
+		{ResultSet: true},                    // TABLE
+		{ResultSet: true},                    // VALUES
+		{Begin: true},                        // START TRANSACTION
+		{Commit: true},                       // COMMIT
+		{Write: true},                        // INSERT
+		{DDL: true},                          // TRUNCATE
+	}
+
+	trxList := []config.Trx{
+		{
+			Name: "classify", // must set because we don't call Validate
+			File: "../test/trx/classify.sql",
+		},
+	}
+	got, err := trx.Load(trxList, data.NewScope(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotClass := []class{}
+	for _, s := range got.Statements["classify"] {
+		gotClass = append(gotClass, class{
+			ResultSet:   s.ResultSet,
+			LockingRead: s.LockingRead,
+			Write:       s.Write,
+			DDL:         s.DDL,
+			Begin:       s.Begin,
+			Commit:      s.Commit,
+		})
+	}
+	if diff := deep.Equal(gotClass, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestLoad_Call(t *testing.T) {
+	trxList := []config.Trx{
+		{
+			Name: "call", // must set because we don't call Validate
+			File: "../test/trx/call.sql",
+		},
+	}
+	got, err := trx.Load(trxList, data.NewScope(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts := got.Statements["call"]
+	if len(stmts) != 3 {
+		t.Fatalf("got %d statements, expected 3", len(stmts))
+	}
+	if !stmts[0].Call || stmts[0].CallResult != 1 {
+		t.Errorf("CALL p1: Call=%t CallResult=%d, expected true and 1 (default)", stmts[0].Call, stmts[0].CallResult)
+	}
+	if !stmts[1].Call || stmts[1].CallResult != 2 || len(stmts[1].Outputs) != 1 {
+		t.Errorf("CALL p2: Call=%t CallResult=%d Outputs=%v, expected true, 2, and 1 output", stmts[1].Call, stmts[1].CallResult, stmts[1].Outputs)
+	}
+
+	// result-set is only valid on CALL
+	file := filepath.Join(t.TempDir(), "select.sql")
+	if err := os.WriteFile(file, []byte("-- result-set: 2\nSELECT 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	trxList = []config.Trx{{Name: "select", File: file}}
+	if _, err := trx.Load(trxList, data.NewScope(), p); err == nil {
+		t.Error("no error for result-set on SELECT")
+	}
+}
+
+func TestLoad_Batch(t *testing.T) {
+	trxList := []config.Trx{
+		{
+			Name: "batch", // must set because we don't call Validate
+			File: "../test/trx/batch.sql",
+		},
+	}
+	got, err := trx.Load(trxList, data.NewScope(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := []int{}
+	for _, s := range got.Statements["batch"] {
+		batch = append(batch, s.Batch)
+	}
+	if diff := deep.Equal(batch, []int{3, 0, 0, 0}); diff != nil {
+		t.Error(diff)
+	}
+	if !got.Meta["batch"].Batch {
+		t.Error("Meta.Batch is false, expected true")
+	}
+
+	invalid := map[string]string{
+		"too-long": "-- batch: 3\nBEGIN\n\nCOMMIT\n",
+		"prepare":  "-- batch: 2\nBEGIN\n\n-- prepare\nSELECT 1\n",
+		"min":      "-- batch: 1\nSELECT 1\n",
+	}
+	dir := t.TempDir()
+	for name, content := range invalid {
+		file := filepath.Join(dir, name+".sql")
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		trxList = []config.Trx{{Name: name, File: file}}
+		if _, err := trx.Load(trxList, data.NewScope(), p); err == nil {
+			t.Errorf("no error for invalid batch: %s", name)
+		}
+	}
+}
+
+func TestLoad_Include(t *testing.T) {
+	// Named blocks from include/common.sql are parsed in place of the include
+	// directives, so the result is the same as if the statements were written
+	// in include.sql
+	trxList := []config.Trx{
+		{
+			Name: "include.sql", // must set because we don't call Validate
+			File: "../test/trx/include.sql",
+		},
+	}
+
+	scope := data.NewScope()
+	got, err := trx.Load(trxList, scope, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []*trx.Statement{
+		{
+			Trx:   "include.sql",
+			Query: "BEGIN",
+			Begin: true,
+		},
+		{
+			Trx:       "include.sql",
+			Query:     "select c from t1 where id=1",
+			ResultSet: true,
+			Outputs:   []string{"@c"},
+		},
+		{
+			Trx:    "include.sql",
+			Query:  "update t1 set c=%v where id=1",
+			Write:  true,
+			Inputs: []string{"@c"},
+			Calls:  []byte{0},
+		},
+		{
+			Trx:    "include.sql",
+			Query:  "COMMIT",
+			Commit: true,
+		},
+	}
+	if diff := deep.Equal(got.Statements["include.sql"], expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Include cycle must be detected, not recurse forever
+	trxList = []config.Trx{
+		{
+			Name: "include-cycle.sql",
+			File: "../test/trx/include-cycle.sql",
+		},
+	}
+	_, err = trx.Load(trxList, data.NewScope(), p)
+	if err == nil {
+		t.Error("no error on include cycle, expected an error")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	got, err := trx.Expand("../test/trx/include.sql", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `BEGIN
+
+
+-- save-columns: @c
+select c from t1 where id=1
+
+
+
+update t1 set c=@c where id=1
+
+
+COMMIT
+`
+	if string(got) != expect {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expect)
+	}
+}
+
 func TestLoad_RowScopeCSV(t *testing.T) {
 	file := "rowscope-csv.sql"
 	trxList := []config.Trx{
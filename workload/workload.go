@@ -3,6 +3,7 @@
 package workload
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/square/finch/config"
 	"github.com/square/finch/data"
 	"github.com/square/finch/dbconn"
+	"github.com/square/finch/idle"
 	"github.com/square/finch/limit"
 	"github.com/square/finch/stats"
 	"github.com/square/finch/trx"
@@ -29,7 +31,10 @@ type Allocator struct {
 	Workload  []config.ClientGroup // config.stage.workload
 	StageQPS  limit.Rate           // config.stage.qps
 	StageTPS  limit.Rate           // config.stage.tps
+	Errors    config.Errors        // config.stage.errors
 	DoneChan  chan *client.Client  // Stage.doneChan
+	EventTrx  map[string]bool      // config.Stage.EventTrx: not auto-assigned
+	Bytes     bool                 // config.Stats.Throughput: clients count bytes received
 }
 
 // ClientGroup is a runnable group of clients created from a config.ClientGroup.
@@ -60,7 +65,7 @@ func (a *Allocator) Groups() ([][]int, error) {
 	for i := range a.Workload {
 		if len(a.Workload[i].Trx) == 0 {
 			finch.Debug("cg %d: all trx", i)
-			a.Workload[i].Trx = a.TrxSet.Order
+			a.Workload[i].Trx = a.workloadTrx()
 		}
 
 		hasDDL := a.hasDDL(a.Workload[i].Trx)
@@ -73,8 +78,9 @@ func (a *Allocator) Groups() ([][]int, error) {
 			continue
 		}
 
-		// Does any trx have DDL?
-		if hasDDL {
+		// Does any trx have DDL? Unless concurrent-ddl, DDL runs alone in its own
+		// exec group. With concurrent-ddl, it runs in the same exec group as DML.
+		if hasDDL && !a.Workload[i].ConcurrentDDL {
 			ddlNo += 1
 			a.Workload[i].Group = fmt.Sprintf("ddl%d", ddlNo)
 			prevHasDDL = true
@@ -157,23 +163,66 @@ func (a *Allocator) Clients(groups [][]int, withStats bool) ([][]ClientGroup, er
 
 			var clientsIterPtr uint32
 
-			db, _, err := dbconn.Make() // stage already validated connection
+			// MySQL connection: stage.mysql or workload[].mysql. Stage already
+			// validated both.
+			var db *sql.DB
+			var dsn string
+			var err error
+			var opts []dbconn.Option
+			for _, trxName := range cg.Trx {
+				if a.TrxSet.Meta[trxName].Batch {
+					finch.Debug("multi-statements")
+					opts = append(opts, dbconn.MultiStatements) // only for batches
+					break
+				}
+			}
+			if cg.MySQL != nil {
+				db, dsn, err = dbconn.MakeWith(*cg.MySQL, opts...)
+			} else {
+				db, dsn, err = dbconn.Make(opts...)
+			}
 			if err != nil {
 				return nil, err
 			}
+			target := dbconn.Addr(dsn) // stats per MySQL target
 			if finch.ModifyDB != nil {
 				finch.ModifyDB(db, runlevel)
 			}
 
+			errorHandling := a.errorHandling(cg)
+			timeout, _ := time.ParseDuration(cg.Timeout) // already validated
+			session := cg.Session.SQL()
+			conn, _ := config.ParseConnection(cg.Connection) // already validated
+			var idleIter, idleTrx idle.Time
+			if cg.IdleIter != "" {
+				idleIter, _ = idle.Parse(cg.IdleIter) // already validated
+			}
+			if cg.IdleTrx != "" {
+				idleTrx, _ = idle.Parse(cg.IdleTrx) // already validated
+			}
+
 			for k := uint(0); k < nClients; k++ { // ------------------- CLIENT
 				runlevel.Client = k + 1
 				c := &client.Client{
 					RunLevel:  runlevel,
 					DB:        db,         // *sql.DB
 					DefaultDb: cg.Db,      // default database
+					Session:   session,    // SET SESSION variables
 					DoneChan:  a.DoneChan, // <- *Client
 					Iter:      finch.Uint(cg.Iter),
 					Stats:     make([]*stats.Trx, len(cg.Trx)), // Client requires slice but values can be nil
+					Bytes:     withStats && a.Bytes,
+
+					ErrorHandling: errorHandling, // stage.errors and workload[].errors
+					Timeout:       timeout,       // default statement timeout
+					IdleIter:      idleIter,      // think time between iterations
+					IdleTrx:       idleTrx,       // think time between trx
+
+					Retries: finch.Uint(cg.Retries), // max finch.Eretry per trx
+
+					ConnTrx:  conn.Trx,  // new connection every trx,
+					ConnIter: conn.Iter, // every N iterations,
+					ConnTime: conn.Time, // or every duration
 				}
 
 				// Set combined limits, if any: iterations, QPS, TPS
@@ -218,6 +267,7 @@ func (a *Allocator) Clients(groups [][]int, withStats bool) ([][]ClientGroup, er
 					// for this client group
 					if withStats && !cg.DisableStats {
 						c.Stats[trxNo] = stats.NewTrx(trxName)
+						c.Stats[trxNo].Target = target
 					}
 
 					for _, stmt := range a.TrxSet.Statements[trxName] { // STMT
@@ -289,7 +339,7 @@ func (a *Allocator) Clients(groups [][]int, withStats bool) ([][]ClientGroup, er
 func (a *Allocator) AutoAssign() []config.ClientGroup {
 	cg := []config.ClientGroup{}
 	prevHasDDL := true
-	for _, trxName := range a.TrxSet.Order {
+	for _, trxName := range a.workloadTrx() {
 		if a.TrxSet.Meta[trxName].DDL {
 			// Trx with DDL, must exec alone
 			finch.Debug("auto: %s (DDL)", trxName)
@@ -319,6 +369,84 @@ func (a *Allocator) AutoAssign() []config.ClientGroup {
 	return cg
 }
 
+// errorHandling returns finch.MySQLErrorHandling with config.stage.errors and
+// then cg.errors applied, or nil if neither is set, in which case Client.Init
+// uses finch.MySQLErrorHandling.
+func (a *Allocator) errorHandling(cg config.ClientGroup) map[uint16]byte {
+	if len(a.Errors) == 0 && len(cg.Errors) == 0 {
+		return nil
+	}
+	eh := make(map[uint16]byte, len(finch.MySQLErrorHandling))
+	for code, flags := range finch.MySQLErrorHandling {
+		eh[code] = flags
+	}
+	for _, errs := range []config.Errors{a.Errors, cg.Errors} {
+		flags, _ := errs.Flags() // already validated
+		for code, f := range flags {
+			eh[code] = f
+		}
+	}
+	finch.Debug("error handling: %v", eh)
+	return eh
+}
+
+// Events returns one client for each stage event (config.stage.events) that
+// executes the event trx once (iter=1) each time it's run. Event clients are
+// not rate limited. If withStats is true, each client has its own stats.Trx
+// that Stage collects and reports separately from the workload stats.
+// Each client has its own DoneChan so Stage can run it synchronously.
+func (a *Allocator) Events(events []config.Event, withStats bool) ([]*client.Client, error) {
+	ea := *a // copy to allocate events like client groups without modifying a
+	ea.StageQPS = nil
+	ea.StageTPS = nil
+	ea.Workload = make([]config.ClientGroup, len(events))
+	groups := make([][]int, len(events))
+	for i, e := range events {
+		ea.Workload[i] = config.ClientGroup{
+			Group:   fmt.Sprintf("event%d", i+1),
+			Clients: "1",
+			Iter:    "1",
+			Trx:     []string{e.Trx},
+		}
+		groups[i] = []int{i}
+	}
+	cgs, err := ea.Clients(groups, withStats)
+	if err != nil {
+		return nil, err
+	}
+	clients := make([]*client.Client, len(events))
+	for i := range cgs {
+		clients[i] = cgs[i][0].Clients[0]
+		clients[i].DoneChan = make(chan *client.Client, 1)
+	}
+	return clients, nil
+}
+
+// workloadTrx returns all trx in order except trx used only by events.
+func (a *Allocator) workloadTrx() []string {
+	if len(a.EventTrx) == 0 {
+		return a.TrxSet.Order
+	}
+	trxNames := make([]string, 0, len(a.TrxSet.Order))
+	for _, trxName := range a.TrxSet.Order {
+		if !a.EventTrx[trxName] {
+			trxNames = append(trxNames, trxName)
+		}
+	}
+	return trxNames
+}
+
+// ConcurrentDDL returns true if any client group has DDL and concurrent-ddl,
+// so DDL runs in the same exec group as DML. It must be called after Groups.
+func (a *Allocator) ConcurrentDDL() bool {
+	for i := range a.Workload {
+		if a.Workload[i].ConcurrentDDL && a.hasDDL(a.Workload[i].Trx) {
+			return true
+		}
+	}
+	return false
+}
+
 func (a *Allocator) hasDDL(trxNames []string) bool {
 	for _, trxName := range trxNames {
 		if a.TrxSet.Meta[trxName].DDL {
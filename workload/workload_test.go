@@ -238,6 +238,132 @@ func TestGroups_PartialAlloc(t *testing.T) {
 	}
 }
 
+func TestEvents(t *testing.T) {
+	// 003.sql is used only by an event, so it's not auto-assigned to the workload.
+	// Instead, Events returns a dedicated client for it.
+	trxList := []config.Trx{
+		{
+			Name: "001.sql", // must set; Validate not called
+			File: "../test/trx/001.sql",
+			Data: map[string]config.Data{
+				"id": {
+					Generator: "auto-inc",
+				},
+			},
+		},
+		{
+			Name: "003.sql",
+			File: "../test/trx/003.sql",
+		},
+	}
+	scope := data.NewScope()
+	set, err := trx.Load(trxList, scope, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []config.Event{{At: "1s", Trx: "003.sql"}}
+	a := workload.Allocator{
+		Stage:     1,
+		StageName: "events",
+		TrxSet:    set,
+		Workload:  []config.ClientGroup{}, // NO WORKLOAD
+		DoneChan:  make(chan *client.Client, 1),
+		EventTrx:  map[string]bool{"003.sql": true},
+	}
+	if _, err := a.Groups(); err != nil {
+		t.Fatal(err)
+	}
+	eg := []config.ClientGroup{
+		{
+			Group:   "dml1",
+			Clients: "1",
+			Trx:     []string{"001.sql"}, // auto-assigned, not 003.sql
+		},
+	}
+	if diff := deep.Equal(a.Workload, eg); diff != nil {
+		t.Error(diff)
+		t.Logf("got: %#v", a.Workload)
+	}
+
+	clients, err := a.Events(events, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 1 {
+		t.Fatalf("got %d event clients, expected 1", len(clients))
+	}
+	c := clients[0]
+	if got := c.RunLevel.ClientId(); got != "1(events)/e1(event1)/g1/c1" {
+		t.Errorf("got client id %s, expected 1(events)/e1(event1)/g1/c1", got)
+	}
+	if c.Iter != 1 {
+		t.Errorf("got iter %d, expected 1", c.Iter)
+	}
+	if diff := deep.Equal(c.Statements, set.Statements["003.sql"]); diff != nil {
+		t.Error(diff)
+	}
+	if c.DoneChan == nil || c.DoneChan == a.DoneChan {
+		t.Error("event client does not have its own DoneChan")
+	}
+	if len(c.Stats) != 1 || c.Stats[0] == nil || c.Stats[0].Name != "003.sql" {
+		t.Errorf("event client does not have its own trx stats: %+v", c.Stats)
+	}
+}
+
+func TestGroups_ConcurrentDDL(t *testing.T) {
+	// Trx with DDL normally run alone in their own exec group (ddlN), but
+	// concurrent-ddl puts the DDL client group in the same exec group as DML.
+	set := &trx.Set{
+		Order: []string{"alter.sql", "dml.sql"},
+		Meta: map[string]trx.Meta{
+			"alter.sql": {DDL: true},
+			"dml.sql":   {},
+		},
+	}
+	a := workload.Allocator{
+		Stage:     1,
+		StageName: "test",
+		TrxSet:    set,
+		Workload: []config.ClientGroup{
+			{Clients: "1", Trx: []string{"alter.sql"}},
+			{Clients: "4", Trx: []string{"dml.sql"}},
+		},
+	}
+	gotGroups, err := a.Groups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(gotGroups, [][]int{{0}, {1}}); diff != nil {
+		t.Error(diff)
+	}
+	if a.ConcurrentDDL() {
+		t.Error("ConcurrentDDL true, expected false")
+	}
+
+	a.Workload = []config.ClientGroup{
+		{Clients: "1", Trx: []string{"alter.sql"}, ConcurrentDDL: true},
+		{Clients: "4", Trx: []string{"dml.sql"}},
+	}
+	gotGroups, err = a.Groups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(gotGroups, [][]int{{0, 1}}); diff != nil {
+		t.Error(diff)
+	}
+	eg := []config.ClientGroup{
+		{Group: "dml1", Clients: "1", Iter: "1", Trx: []string{"alter.sql"}, ConcurrentDDL: true},
+		{Group: "dml1", Clients: "4", Trx: []string{"dml.sql"}},
+	}
+	if diff := deep.Equal(a.Workload, eg); diff != nil {
+		t.Error(diff)
+	}
+	if !a.ConcurrentDDL() {
+		t.Error("ConcurrentDDL false, expected true")
+	}
+}
+
 func TestGroups_ClientGroups(t *testing.T) {
 	stage, err := config.Load([]string{"../test/run/scope/workload_cg_alloc.yaml"}, nil, "dsn", "db")
 	if err != nil {